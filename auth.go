@@ -0,0 +1,172 @@
+package sawyer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// An Authenticator adds credentials to outgoing requests.  A Client's
+// Authenticator is copied to every Request created with NewRequest(), and is
+// applied before the Request's middleware chain and each time it is sent, using
+// the Request's context.  The credentials are part of the cache key.
+type Authenticator interface {
+	Authenticate(*http.Request) error
+}
+
+// A RefreshingAuthenticator is an Authenticator whose credentials can be
+// refreshed.  When a request with a RefreshingAuthenticator gets a 401
+// response, the credentials are refreshed and the request is sent once more.
+type RefreshingAuthenticator interface {
+	Authenticator
+	Refresh(context.Context) error
+}
+
+// BasicAuth is an Authenticator that sets HTTP Basic credentials.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements the Authenticator interface.
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerToken is an Authenticator that sets a static bearer token.
+type BearerToken string
+
+// Authenticate implements the Authenticator interface.
+func (t BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set(authorizationHeader, bearerPrefix+string(t))
+	return nil
+}
+
+// A TokenSource fetches a new bearer token.  It is called the first time a
+// token is needed, and every time it is refreshed.
+type TokenSource interface {
+	Token(context.Context) (string, error)
+}
+
+// TokenSourceFunc is a func that implements the TokenSource interface.
+type TokenSourceFunc func(context.Context) (string, error)
+
+// Token implements the TokenSource interface.
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// TokenAuth is a RefreshingAuthenticator that sets a bearer token from a
+// TokenSource.  The token is kept until it is refreshed.  It is safe to share
+// between goroutines.
+type TokenAuth struct {
+	Source TokenSource
+	mutex  sync.Mutex
+	token  string
+}
+
+// NewTokenAuth returns a TokenAuth for the given TokenSource.
+func NewTokenAuth(source TokenSource) *TokenAuth {
+	return &TokenAuth{Source: source}
+}
+
+// Authenticate implements the Authenticator interface.  The first call fetches
+// a token from the TokenSource.
+func (a *TokenAuth) Authenticate(req *http.Request) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.token) == 0 {
+		token, err := a.Source.Token(req.Context())
+		if err != nil {
+			return err
+		}
+		a.token = token
+	}
+
+	req.Header.Set(authorizationHeader, bearerPrefix+a.token)
+	return nil
+}
+
+// setToken sets the token on the request, if the TokenAuth has one.
+func (a *TokenAuth) setToken(req *http.Request) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.token) > 0 {
+		req.Header.Set(authorizationHeader, bearerPrefix+a.token)
+	}
+}
+
+// Refresh implements the RefreshingAuthenticator interface by fetching a new
+// token from the TokenSource.
+func (a *TokenAuth) Refresh(ctx context.Context) error {
+	token, err := a.Source.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	a.token = token
+	a.mutex.Unlock()
+	return nil
+}
+
+// authenticate adds the Authenticator's credentials to the Request.
+func (r *Request) authenticate() error {
+	if r.Authenticator == nil {
+		return nil
+	}
+	return r.Authenticator.Authenticate(r.Request)
+}
+
+// authenticateKey adds the credentials that the cache key is built from.  An
+// offline Request never touches the network, so it only gets the credentials
+// that the Authenticator already has.
+func (r *Request) authenticateKey() error {
+	if r.Offline {
+		return r.authenticateOffline()
+	}
+	return r.authenticate()
+}
+
+// authenticateOffline adds the Authenticator's credentials without fetching
+// any.  A RefreshingAuthenticator may have to fetch them, so it is skipped,
+// unless it is a TokenAuth that already has a token.
+func (r *Request) authenticateOffline() error {
+	switch auth := r.Authenticator.(type) {
+	case nil:
+		return nil
+	case *TokenAuth:
+		auth.setToken(r.Request)
+		return nil
+	case RefreshingAuthenticator:
+		return nil
+	}
+	return r.Authenticator.Authenticate(r.Request)
+}
+
+// refreshAuth determines if the given response should be retried with refreshed
+// credentials.  If so, the Request's Authenticator refreshes its credentials,
+// which are added to the Request when it is sent again.
+func (r *Request) refreshAuth(res *http.Response) (bool, error) {
+	if res.StatusCode != http.StatusUnauthorized {
+		return false, nil
+	}
+
+	auth, ok := r.Authenticator.(RefreshingAuthenticator)
+	if !ok || (r.Body != nil && r.GetBody == nil) {
+		return false, nil
+	}
+
+	if err := auth.Refresh(r.Context()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
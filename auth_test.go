@@ -0,0 +1,261 @@
+package sawyer
+
+import (
+	"context"
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.Equal(t, true, ok)
+		assert.Equal(t, "sawyer", user)
+		assert.Equal(t, "secret", pass)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	client := setup.Client
+	client.Authenticator = &BasicAuth{"sawyer", "secret"}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 204, res.StatusCode)
+}
+
+func TestBearerToken(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	client := setup.Client
+	client.Authenticator = BearerToken("abc")
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 204, res.StatusCode)
+}
+
+func TestTokenAuthError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tokenErr := errors.New("no token")
+	client := setup.Client
+	client.Authenticator = NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "", tokenErr
+	}))
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, true, res.IsError())
+	assert.Equal(t, tokenErr, res.ResponseError)
+	assert.Equal(t, 0, res.Attempts)
+	assert.Equal(t, 0, requests)
+}
+
+func TestTokenAuthUsesRequestContext(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	type ctxKey struct{}
+	var tokenCtx context.Context
+	client := setup.Client
+	client.Authenticator = NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		tokenCtx = ctx
+		return "token", nil
+	}))
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", req.Header.Get("Authorization"))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "sawyer")
+	res := req.GetContext(ctx)
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, "sawyer", tokenCtx.Value(ctxKey{}))
+}
+
+func TestTokenAuthRefreshesOn401(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	mtype, err := mediatype.Parse("application/json")
+	assert.Equal(t, nil, err)
+
+	logins := []string{}
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		user := &TestUser{}
+		mtype.Decode(user, r.Body)
+		logins = append(logins, user.Login)
+
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tokens := 0
+	client := setup.Client
+	client.Authenticator = NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		tokens += 1
+		return "token-" + strconv.Itoa(tokens), nil
+	}))
+
+	req, err := client.NewRequest("users")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, req.SetBody(mtype, &TestUser{Login: "sawyer"}))
+
+	res := req.Post()
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, 2, res.Attempts)
+	assert.Equal(t, 2, tokens)
+	assert.Equal(t, []string{"sawyer", "sawyer"}, logins)
+}
+
+func TestTokenAuthRefreshesOnce(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	client := setup.Client
+	client.Authenticator = NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "token", nil
+	}))
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, 2, res.Attempts)
+	assert.Equal(t, 2, requests)
+}
+
+func TestStaticTokenDoesNotRetry(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	client := setup.Client
+	client.Authenticator = BearerToken("abc")
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, 1, requests)
+}
+
+func TestTokenAuthRefreshError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"bad token"}`))
+	})
+
+	tokens := 0
+	refreshErr := errors.New("token revoked")
+	client := setup.Client
+	client.ApiError = &TestError{}
+	client.Authenticator = NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		tokens += 1
+		if tokens > 1 {
+			return "", refreshErr
+		}
+		return "token", nil
+	}))
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, true, errors.Is(res, refreshErr))
+
+	var apierr *TestError
+	assert.Equal(t, true, errors.As(res.ApiError(), &apierr))
+	assert.Equal(t, "bad token", apierr.Message)
+}
+
+func TestAuthenticateBeforeCacheLookup(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cacher := &authCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+	client.Authenticator = BearerToken("abc")
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, "Bearer abc", cacher.Authorization)
+}
+
+// authCacher records the Authorization header of the cache lookup, which is
+// part of the cache key.
+type authCacher struct {
+	Authorization string
+	*noOpCache
+}
+
+func (c *authCacher) Get(req *http.Request) (CachedResponse, error) {
+	c.Authorization = req.Header.Get("Authorization")
+	return c.noOpCache.Get(req)
+}
@@ -40,6 +40,36 @@ type CachedResponse interface {
 	IsExpired() bool
 }
 
+// StaleCachedResponse is a CachedResponse that may be served after it expires,
+// as described in RFC 5861.
+type StaleCachedResponse interface {
+	CachedResponse
+
+	// StaleWhileRevalidate returns true if the expired response can be served
+	// while it is revalidated in the background.
+	StaleWhileRevalidate() bool
+
+	// StaleIfError returns true if the expired response can be served when the
+	// request fails.
+	StaleIfError() bool
+}
+
+// A PrefixInvalidator is a Cacher that can invalidate the cached responses for
+// every URL that starts with a prefix.
+type PrefixInvalidator interface {
+	// InvalidatePrefix removes the cached responses for URLs starting with the
+	// given absolute URL prefix, but leaves the cached relations.
+	InvalidatePrefix(prefix string) error
+}
+
+// A KeyedCacher is a Cacher that can report the cache key for a request.  The
+// key keeps a stale response from being revalidated in the background more
+// than once at a time.
+type KeyedCacher interface {
+	// CacheKey returns the key that the request's response is cached under.
+	CacheKey(*http.Request) string
+}
+
 type noOpCache struct{}
 
 func (c *noOpCache) Get(req *http.Request) (CachedResponse, error) {
@@ -0,0 +1,89 @@
+package sawyer
+
+import (
+	"errors"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+var (
+	// ErrBodyClosed is returned when decoding a Response whose body has already
+	// been read and closed.
+	ErrBodyClosed = errors.New("Body is already closed")
+
+	// ErrNoMediaType is returned when decoding a Response without a
+	// Content-Type.
+	ErrNoMediaType = errors.New("No media type for this response")
+
+	// ErrExistingResponseError is returned when decoding a Response that already
+	// has a ResponseError.
+	ErrExistingResponseError = errors.New("Existing Response error")
+
+	// ErrNoResource is returned when decoding into a nil resource.
+	ErrNoResource = errors.New("No resource")
+
+	// ErrInvalidPageValue is returned by a Pager when Next is given a value that
+	// isn't a non-nil pointer.
+	ErrInvalidPageValue = errors.New("Page value must be a non-nil pointer")
+)
+
+// A StatusError is returned by a Pager when a page's API error response has no
+// error message of its own.  Its message is the HTTP status, such as
+// "404 Not Found".
+type StatusError struct {
+	Response *Response
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Response.Status) > 0 {
+		return e.Response.Status
+	}
+	return strconv.Itoa(e.Response.StatusCode) + " " + http.StatusText(e.Response.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Response
+}
+
+// A DecodeError is returned when a response body can't be decoded with the
+// decoder for its MediaType.
+type DecodeError struct {
+	MediaType *mediatype.MediaType
+	Err       error
+}
+
+func (e *DecodeError) Error() string {
+	return "Error decoding " + e.MediaType.String() + " response: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// A RequestError is set as the ResponseError when the net/http Client fails to
+// complete the request.  Err is the error from the Client, usually a
+// *url.Error.
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	err := e.Err
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	return e.Method + " " + e.URL + ": " + err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// requestError wraps the given error from the net/http Client.
+func requestError(r *Request, err error) error {
+	return &RequestError{Method: r.Method, URL: r.URL.String(), Err: err}
+}
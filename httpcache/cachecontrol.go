@@ -0,0 +1,170 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl is a parsed Cache-Control header.  The keys are the lowercased
+// directive names, and the values are the unquoted directive arguments.
+//
+//	cc := ParseCacheControl(res.Header)
+//	if maxAge, ok := cc.MaxAge(); ok {
+//	  // ...
+//	}
+type CacheControl map[string]string
+
+// ParseCacheControl parses the directives of all Cache-Control fields in the
+// given header.  Only the first occurrence of a directive is kept.
+func ParseCacheControl(header http.Header) CacheControl {
+	cc := make(CacheControl)
+	for _, value := range header.Values(cacheControlHeader) {
+		for _, directive := range splitDirectives(value) {
+			pieces := strings.SplitN(directive, "=", 2)
+			name := strings.ToLower(strings.TrimSpace(pieces[0]))
+			if len(name) == 0 {
+				continue
+			}
+
+			if _, ok := cc[name]; ok {
+				continue
+			}
+
+			arg := ""
+			if len(pieces) > 1 {
+				arg = strings.Trim(strings.TrimSpace(pieces[1]), `"`)
+			}
+			cc[name] = arg
+		}
+	}
+	return cc
+}
+
+// Has returns true if the given directive is set.
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// NoStore returns true if the response must not be stored.
+func (cc CacheControl) NoStore() bool {
+	return cc.Has("no-store")
+}
+
+// NoCache returns true if the response must be revalidated before every use.
+func (cc CacheControl) NoCache() bool {
+	return cc.Has("no-cache")
+}
+
+// Private returns true if the response must not be stored by a shared cache.
+func (cc CacheControl) Private() bool {
+	return cc.Has("private")
+}
+
+// Public returns true if any cache may store the response.
+func (cc CacheControl) Public() bool {
+	return cc.Has("public")
+}
+
+// MustRevalidate returns true if the response must not be used once it is
+// stale, without revalidating it first.
+func (cc CacheControl) MustRevalidate() bool {
+	return cc.Has("must-revalidate")
+}
+
+// Immutable returns true if the response will not change while it is fresh.
+func (cc CacheControl) Immutable() bool {
+	return cc.Has("immutable")
+}
+
+// MaxAge returns the max-age directive.  An invalid value is treated as zero,
+// so the response is stale.  It returns false if max-age is not set.
+func (cc CacheControl) MaxAge() (time.Duration, bool) {
+	return cc.seconds("max-age")
+}
+
+// SMaxAge returns the s-maxage directive, which only applies to shared caches.
+func (cc CacheControl) SMaxAge() (time.Duration, bool) {
+	return cc.seconds("s-maxage")
+}
+
+// StaleWhileRevalidate returns the stale-while-revalidate directive from RFC
+// 5861.
+func (cc CacheControl) StaleWhileRevalidate() (time.Duration, bool) {
+	return cc.seconds("stale-while-revalidate")
+}
+
+// StaleIfError returns the stale-if-error directive from RFC 5861.
+func (cc CacheControl) StaleIfError() (time.Duration, bool) {
+	return cc.seconds("stale-if-error")
+}
+
+func (cc CacheControl) seconds(directive string) (time.Duration, bool) {
+	arg, ok := cc[directive]
+	if !ok || len(arg) == 0 {
+		return 0, false
+	}
+
+	secs, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, true
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// Storable returns true if a response to the given request may be stored.  The
+// response must not have the no-store directive, in either the request or the
+// response.  Private responses are not stored by shared caches, and responses
+// that vary on "*" are never stored.  A shared cache only stores a response to
+// an authorized request if the response is public, must-revalidate, or has an
+// s-maxage directive.
+func Storable(req *http.Request, res *http.Response, shared bool) bool {
+	if req != nil && ParseCacheControl(req.Header).NoStore() {
+		return false
+	}
+
+	if _, ok := varyFields(res.Header); !ok {
+		return false
+	}
+
+	cc := ParseCacheControl(res.Header)
+	if cc.NoStore() {
+		return false
+	}
+
+	if !shared {
+		return true
+	}
+
+	if cc.Private() {
+		return false
+	}
+
+	if req != nil && len(req.Header.Get(authHeader)) > 0 {
+		_, ok := cc.SMaxAge()
+		return ok || cc.Public() || cc.MustRevalidate()
+	}
+	return true
+}
+
+// splitDirectives splits a Cache-Control value on commas that are not inside a
+// quoted argument, like no-cache="Set-Cookie, Set-Cookie2".
+func splitDirectives(value string) []string {
+	var directives []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			inQuote = !inQuote
+		case ',':
+			if !inQuote {
+				directives = append(directives, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(directives, value[start:])
+}
@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	header := http.Header{}
+	header.Add("Cache-Control", `max-age=60, private, no-cache="Set-Cookie, Set-Cookie2"`)
+	header.Add("Cache-Control", "S-MAXAGE=120, must-revalidate, immutable, max-age=5")
+
+	cc := ParseCacheControl(header)
+	assert.Equal(t, true, cc.Private())
+	assert.Equal(t, false, cc.Public())
+	assert.Equal(t, true, cc.NoCache())
+	assert.Equal(t, "Set-Cookie, Set-Cookie2", cc["no-cache"])
+	assert.Equal(t, false, cc.NoStore())
+	assert.Equal(t, true, cc.MustRevalidate())
+	assert.Equal(t, true, cc.Immutable())
+
+	maxAge, ok := cc.MaxAge()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, time.Minute, maxAge)
+
+	sMaxAge, ok := cc.SMaxAge()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 2*time.Minute, sMaxAge)
+}
+
+func TestParseStaleDirectives(t *testing.T) {
+	header := http.Header{"Cache-Control": {"max-age=0, stale-while-revalidate=30, stale-if-error=600"}}
+	cc := ParseCacheControl(header)
+
+	swr, ok := cc.StaleWhileRevalidate()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 30*time.Second, swr)
+
+	sie, ok := cc.StaleIfError()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 10*time.Minute, sie)
+}
+
+func TestParseInvalidMaxAge(t *testing.T) {
+	cc := ParseCacheControl(http.Header{"Cache-Control": {"max-age=soon"}})
+	maxAge, ok := cc.MaxAge()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, time.Duration(0), maxAge)
+
+	cc = ParseCacheControl(http.Header{"Cache-Control": {"public"}})
+	_, ok = cc.MaxAge()
+	assert.Equal(t, false, ok)
+}
+
+func TestStorable(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+
+	res := &http.Response{Header: http.Header{"Cache-Control": {"max-age=60, private"}}}
+	assert.Equal(t, true, Storable(req, res, false))
+	assert.Equal(t, false, Storable(req, res, true))
+
+	res.Header.Set("Cache-Control", "no-store")
+	assert.Equal(t, false, Storable(req, res, false))
+
+	res.Header.Set("Cache-Control", "max-age=60")
+	req.Header.Set("Cache-Control", "no-store")
+	assert.Equal(t, false, Storable(req, res, false))
+}
+
+func TestStorableAuthorizedShared(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Authorization", "token a")
+
+	res := &http.Response{Header: http.Header{"Cache-Control": {"max-age=60"}}}
+	assert.Equal(t, true, Storable(req, res, false))
+	assert.Equal(t, false, Storable(req, res, true))
+
+	for _, value := range []string{"max-age=60, public", "max-age=60, must-revalidate", "s-maxage=60"} {
+		res.Header.Set("Cache-Control", value)
+		assert.Equalf(t, true, Storable(req, res, true), "Cache-Control: %s", value)
+	}
+}
@@ -0,0 +1,503 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lostisland/go-sawyer"
+	"github.com/lostisland/go-sawyer/hypermedia"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Cacher is a sawyer.Cacher that keeps its entries in a Store.  It implements
+// the HTTP caching semantics, such as Vary variants, freshness and
+// revalidation, so that a Store only has to hold byte blobs.
+//
+// KeyFunc builds the cache keys, and defaults to RequestKey.  If Shared is set,
+// responses marked "Cache-Control: private" are not stored.
+//
+// Transforms are applied to the response bodies before they are stored, in
+// order, and undone when they are served.  List compression before encryption.
+// Entries that need a transform the Cacher does not have are a miss.  The
+// bodies are transformed with their cache key and Vary variant, so that an
+// AESGCMTransform rejects a body moved to another entry.
+//
+// Each cache key is a single Store value, stored under the cache key itself.
+// Stores that need a fixed-size key, like FileStore, hash it.  Changes to an
+// existing value are read, changed and written under the Cacher's mutex.  If
+// the Store is an UpdatingStore, like FileStore, they are also atomic across
+// processes that share it.
+type Cacher struct {
+	Store      Store
+	KeyFunc    KeyFunc
+	Shared     bool
+	Transforms []BodyTransform
+	mutex      sync.Mutex
+	statsMutex sync.Mutex
+	stats      Stats
+}
+
+// NewCacher returns a Cacher that keeps its entries in the given Store.
+func NewCacher(store Store) *Cacher {
+	return &Cacher{Store: store}
+}
+
+// Get returns the cached response for the request.  A corrupt entry, or one
+// with a body that the Transforms fail to decode, such as after the encryption
+// key changed, is deleted and treated as a miss.
+func (c *Cacher) Get(req *http.Request) (sawyer.CachedResponse, error) {
+	key := c.key(req)
+	entry, err := c.loadEntry(key)
+	if err != nil {
+		c.record(func(s *Stats) { s.Misses += 1 })
+		return nil, err
+	}
+
+	variant, err := entry.variant(req, c)
+	if err != nil {
+		c.record(func(s *Stats) { s.Misses += 1 })
+		return nil, err
+	}
+
+	body, err := c.decodeBody(variant.Body, variant.Transforms, transformKey(key, variant.Key))
+	if err != nil {
+		c.mutex.Lock()
+		c.deleteEntryIf(key, func(entry *storedEntry, err error) bool {
+			if err != nil {
+				return err == CorruptEntryError
+			}
+			variant, err := entry.variant(req, c)
+			if err != nil {
+				return false
+			}
+			_, err = c.decodeBody(variant.Body, variant.Transforms, transformKey(key, variant.Key))
+			return err != nil
+		})
+		c.mutex.Unlock()
+
+		c.record(func(s *Stats) { s.Misses += 1 })
+		return nil, NoResponseError
+	}
+
+	cached := variant.Decoder(c, body)
+
+	cached.checkRequest(req)
+	c.record(func(s *Stats) { s.Hits += 1 })
+	return cached, nil
+}
+
+func (c *Cacher) Set(req *http.Request, res *sawyer.Response) error {
+	if !Storable(req, res.Response, c.Shared) {
+		return nil
+	}
+
+	bodyBuffer := &bytes.Buffer{}
+	if err := EncodeBody(res, bodyBuffer); err != nil {
+		return err
+	}
+
+	cached := newCachedResponse(req, res, c.Shared)
+	key := c.key(req)
+	vkey := variantKey(req.Header, cached.VaryFields)
+
+	body, transforms, err := c.encodeBody(bodyBuffer.Bytes(), transformKey(key, vkey))
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err = c.updateEntry(key, func(entry *storedEntry, err error) (*storedEntry, error) {
+		if replaceable(err) {
+			entry = &storedEntry{}
+		}
+
+		entry.Key = key
+		entry.URL = req.URL.String()
+		entry.setVariant(&storedVariant{
+			Key:        vkey,
+			Response:   cached,
+			Body:       body,
+			Transforms: transforms,
+		})
+		return entry, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.record(func(s *Stats) { s.Stores += 1 })
+	return nil
+}
+
+// Reset removes the cached responses, but keeps the relations.
+func (c *Cacher) Reset(req *http.Request) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.resetEntry(c.key(req))
+}
+
+func (c *Cacher) Clear(req *http.Request) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Store.Delete(c.key(req))
+}
+
+// UpdateCache merges the headers of a 304 response into the cached response,
+// as described in RFC 9111, section 4.3.4, and recalculates its freshness from
+// the merged headers.
+func (c *Cacher) UpdateCache(req *http.Request, res *http.Response) error {
+	key := c.key(req)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.updateEntry(key, func(entry *storedEntry, err error) (*storedEntry, error) {
+		if err == CorruptEntryError {
+			return nil, nil
+		}
+		if replaceable(err) {
+			return nil, NoResponseError
+		}
+
+		variant, err := entry.variant(req, c)
+		if err != nil {
+			return nil, err
+		}
+
+		variant.Response.Header = mergeHeader(variant.Response.Header, res.Header)
+		variant.Response.setFreshness(variant.Response.Header, c.Shared)
+		return entry, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.record(func(s *Stats) { s.NotModified += 1 })
+	return nil
+}
+
+// InvalidatePrefix removes the cached responses for every URL that starts with
+// the given prefix, but keeps the relations.
+func (c *Cacher) InvalidatePrefix(prefix string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys, err := c.keys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		entry, err := c.getEntry(key)
+		if err == CorruptEntryError {
+			err = c.deleteEntryIf(key, corruptEntry)
+		} else if err == nil && strings.HasPrefix(entry.URL, prefix) {
+			err = c.resetEntry(key)
+		}
+
+		if err != nil && !replaceable(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRels stores the relations for the request.  The entry is only rewritten
+// if they changed, since they are set every time a response is decoded.
+func (c *Cacher) SetRels(req *http.Request, rels hypermedia.Relations) error {
+	key := c.key(req)
+	if entry, err := c.getEntry(key); err == nil && equalRels(entry.Relations, rels) {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.updateEntry(key, func(entry *storedEntry, err error) (*storedEntry, error) {
+		if replaceable(err) {
+			entry = &storedEntry{Key: key, URL: req.URL.String()}
+		} else if equalRels(entry.Relations, rels) {
+			return nil, errUnchanged
+		}
+
+		entry.Relations = rels
+		return entry, nil
+	})
+}
+
+func (c *Cacher) Rels(req *http.Request) (hypermedia.Relations, bool) {
+	entry, err := c.loadEntry(c.key(req))
+	if err != nil || entry.Relations == nil {
+		return nil, false
+	}
+	return entry.Relations, true
+}
+
+// storedEntry is the Store value for a cache key.  It has the cache key and
+// request URL, the cached variants of the response, and the relations of the
+// resource.
+type storedEntry struct {
+	Key       string
+	URL       string
+	Variants  []*storedVariant
+	Relations hypermedia.Relations
+}
+
+// storedVariant is a single cached response, for the request header values
+// identified by the Key.  Transforms names the BodyTransforms applied to the
+// Body.
+type storedVariant struct {
+	Key        string
+	Response   *CachedResponse
+	Body       []byte
+	Transforms []string
+}
+
+// variant finds the cached variant that matches the given request.
+func (e *storedEntry) variant(req *http.Request, cacher *Cacher) (*storedVariant, error) {
+	for _, variant := range e.Variants {
+		if variant.Response.MatchesVary(req) && cacher.canDecode(variant.Transforms) {
+			return variant, nil
+		}
+	}
+
+	return nil, NoResponseError
+}
+
+// setVariant adds the variant, replacing any existing variant with the same
+// Key.
+func (e *storedEntry) setVariant(variant *storedVariant) {
+	for i, existing := range e.Variants {
+		if existing.Key == variant.Key {
+			e.Variants[i] = variant
+			return
+		}
+	}
+	e.Variants = append(e.Variants, variant)
+}
+
+// Decoder returns a CachedResponseDecoder for the variant's response, with the
+// given body that the transforms were undone from.
+func (v *storedVariant) Decoder(cacher *Cacher, body []byte) *CachedResponseDecoder {
+	return &CachedResponseDecoder{
+		CachedResponse: v.Response,
+		Cacher:         cacher,
+		SetBodyFunc: func(res *sawyer.Response) {
+			res.Body = ioutil.NopCloser(bytes.NewReader(body))
+			res.BodyClosed = false
+		},
+	}
+}
+
+// Migrate rewrites the entries stored by older versions in the current format,
+// and deletes any corrupt entries.  It returns the number of migrated entries.
+//
+// Older versions of FileCache kept their entries under the old RequestSha, in
+// a layout of their own.  A FileStore's entries are rewritten under the key
+// they were stored with, which is the RequestKey, so they are found by a Cacher
+// with the default KeyFunc.
+func (c *Cacher) Migrate() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	migrated := 0
+	if store, ok := c.Store.(legacyStore); ok {
+		var err error
+		if migrated, err = c.migrateLegacy(store); err != nil {
+			return migrated, err
+		}
+	}
+
+	keys, err := c.keys()
+	if err != nil {
+		return migrated, err
+	}
+
+	for _, key := range keys {
+		value, ok, err := c.Store.Get(key)
+		if err != nil {
+			return migrated, err
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = decodeEntry(value)
+		if err == CorruptEntryError {
+			err = c.deleteEntryIf(key, corruptEntry)
+		}
+
+		if err != nil && err != errEnvelopeVersion {
+			return migrated, err
+		}
+	}
+
+	return migrated, nil
+}
+
+// loadEntry gets the entry for the key without holding the mutex.  A corrupt
+// entry is deleted, and reported as a miss like an entry from a newer version.
+func (c *Cacher) loadEntry(key string) (*storedEntry, error) {
+	entry, err := c.getEntry(key)
+	if err == CorruptEntryError {
+		c.mutex.Lock()
+		c.deleteEntryIf(key, corruptEntry)
+		c.mutex.Unlock()
+	}
+
+	if replaceable(err) {
+		return nil, NoResponseError
+	}
+	return entry, err
+}
+
+func (c *Cacher) getEntry(key string) (*storedEntry, error) {
+	value, ok, err := c.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, NoResponseError
+	}
+
+	return decodeEntry(value)
+}
+
+// updateEntry changes the entry for the key with fn, which gets the stored
+// entry, or the error from getting it, such as NoResponseError if there is
+// none.  If fn returns a nil entry, the entry is deleted, and if it returns
+// errUnchanged, it is left as it is.  The mutex must be held.
+func (c *Cacher) updateEntry(key string, fn func(*storedEntry, error) (*storedEntry, error)) error {
+	return c.update(key, func(value []byte, ok bool) ([]byte, error) {
+		var entry *storedEntry
+		err := NoResponseError
+		if ok {
+			entry, err = decodeEntry(value)
+		}
+
+		entry, err = fn(entry, err)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+		return encodeEntry(entry)
+	})
+}
+
+// deleteEntryIf deletes the entry for the key if fn returns true for it, or for
+// the error from getting it.  The mutex must be held.
+func (c *Cacher) deleteEntryIf(key string, fn func(*storedEntry, error) bool) error {
+	return c.updateEntry(key, func(entry *storedEntry, err error) (*storedEntry, error) {
+		if fn(entry, err) {
+			return nil, nil
+		}
+		return nil, errUnchanged
+	})
+}
+
+// update changes the Store value for the key with fn.  It is atomic across
+// processes if the Store is an UpdatingStore.  A nil value deletes the key.
+// The mutex must be held.
+func (c *Cacher) update(key string, fn func([]byte, bool) ([]byte, error)) error {
+	err := c.updateStore(key, fn)
+	if err == errUnchanged {
+		return nil
+	}
+	return err
+}
+
+func (c *Cacher) updateStore(key string, fn func([]byte, bool) ([]byte, error)) error {
+	if store, ok := c.Store.(UpdatingStore); ok {
+		return store.Update(key, fn)
+	}
+
+	value, ok, err := c.Store.Get(key)
+	if err != nil {
+		return err
+	}
+
+	value, err = fn(value, ok)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return c.Store.Delete(key)
+	}
+	return c.Store.Put(key, value)
+}
+
+// corruptEntry is a deleteEntryIf func for corrupt entries.
+func corruptEntry(entry *storedEntry, err error) bool {
+	return err == CorruptEntryError
+}
+
+// errUnchanged is returned by an updateEntry func to leave the entry as it is.
+var errUnchanged = errors.New("Cache entry unchanged")
+
+// equalRels compares the relations of an entry.  Nil relations aren't equal to
+// empty ones, since Rels() reports if any were set.
+func equalRels(a, b hypermedia.Relations) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+
+	for name, link := range a {
+		if other, ok := b[name]; !ok || other != link {
+			return false
+		}
+	}
+	return true
+}
+
+// keys lists the keys in the Store.
+func (c *Cacher) keys() ([]string, error) {
+	var keys []string
+	err := c.Store.Range(func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+// replaceable returns true if the getEntry error means there is no usable
+// entry, so that a new one can be stored in its place.
+func replaceable(err error) bool {
+	return err == NoResponseError || err == CorruptEntryError || err == errEnvelopeVersion
+}
+
+// resetEntry removes the entry's variants.  The entry is deleted unless it has
+// relations.  The mutex must be held.
+func (c *Cacher) resetEntry(key string) error {
+	return c.updateEntry(key, func(entry *storedEntry, err error) (*storedEntry, error) {
+		if err == NoResponseError {
+			return nil, errUnchanged
+		}
+		if err != nil || entry.Relations == nil {
+			return nil, nil
+		}
+
+		entry.Variants = nil
+		return entry, nil
+	})
+}
+
+// CacheKey returns the cache key for the request, built with the KeyFunc.  It
+// implements the sawyer.KeyedCacher interface.
+func (c *Cacher) CacheKey(req *http.Request) string {
+	return c.key(req)
+}
+
+func (c *Cacher) shared() bool {
+	return c.Shared
+}
+
+func (c *Cacher) key(req *http.Request) string {
+	if c.KeyFunc != nil {
+		return c.KeyFunc(req)
+	}
+	return RequestKey(req)
+}
@@ -0,0 +1,149 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lostisland/go-sawyer"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Coalescer deduplicates concurrent identical GET requests, so that they share
+// a single trip through the cache and to the origin server.  Requests are
+// identical if they have the same KeyFunc key, which defaults to RequestKey.
+// Requests are authenticated before the middleware chain, so the key is
+// partitioned by their credentials.
+// Each caller gets its own copy of the Response, with an independent body.
+//
+//	coalescer := httpcache.NewCoalescer()
+//	client.Use(coalescer.Middleware)
+type Coalescer struct {
+	KeyFunc   KeyFunc
+	mutex     sync.Mutex
+	calls     map[string]*coalescedCall
+	coalesced uint64
+}
+
+// NewCoalescer returns a Coalescer that uses RequestKey.
+func NewCoalescer() *Coalescer {
+	return &Coalescer{}
+}
+
+// Middleware is a sawyer.Middleware that coalesces the Request with any
+// identical Request in flight.
+func (c *Coalescer) Middleware(req *sawyer.Request, next sawyer.HandlerFunc) *sawyer.Response {
+	if req.Method != sawyer.GetMethod || bypassCache(req.Request) {
+		return next(req)
+	}
+
+	key := c.key(req.Request)
+
+	c.mutex.Lock()
+	if c.calls == nil {
+		c.calls = make(map[string]*coalescedCall)
+	}
+
+	if call, ok := c.calls[key]; ok {
+		c.coalesced += 1
+		c.mutex.Unlock()
+		return call.Wait(req, next)
+	}
+
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+	}()
+
+	call.Do(req, next)
+	return call.Response(req, call.res.Attempts)
+}
+
+// Coalesced returns the number of Requests that waited for an identical Request
+// instead of being sent.
+func (c *Coalescer) Coalesced() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.coalesced
+}
+
+func (c *Coalescer) key(req *http.Request) string {
+	if c.KeyFunc != nil {
+		return c.KeyFunc(req)
+	}
+	return RequestKey(req)
+}
+
+// coalescedCall is a Request in flight.  Its Response body is read into memory
+// so that every caller can read it.
+type coalescedCall struct {
+	done chan struct{}
+	res  *sawyer.Response
+	body []byte
+}
+
+// Do runs the Request, and reads the Response body.  If the Request panics, the
+// waiting Requests get an error Response, and the panic continues.
+func (c *coalescedCall) Do(req *sawyer.Request, next sawyer.HandlerFunc) {
+	defer close(c.done)
+	defer func() {
+		if v := recover(); v != nil {
+			c.res = sawyer.ResponseError(fmt.Errorf("Coalesced request panicked: %v", v))
+			panic(v)
+		}
+	}()
+
+	c.res = next(req)
+	if c.res.BodyClosed || c.res.Response == nil || c.res.Body == nil {
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.res.Body)
+	c.res.Body.Close()
+	if err != nil {
+		c.res.ResponseError = err
+		c.res.BodyClosed = true
+		return
+	}
+	c.body = body
+}
+
+// Wait waits for the call in flight, and returns a copy of its Response.  If
+// the call was canceled by its own context, the Request is sent on its own.
+func (c *coalescedCall) Wait(req *sawyer.Request, next sawyer.HandlerFunc) *sawyer.Response {
+	select {
+	case <-c.done:
+	case <-req.Context().Done():
+		return sawyer.ResponseError(req.Context().Err())
+	}
+
+	if c.res.IsCanceled() && req.Context().Err() == nil {
+		return next(req)
+	}
+
+	return c.Response(req, 0)
+}
+
+// Response returns a copy of the call's Response for the given Request.
+func (c *coalescedCall) Response(req *sawyer.Request, attempts int) *sawyer.Response {
+	res := *c.res
+	res.Attempts = attempts
+
+	if c.res.Response != nil {
+		httpres := *c.res.Response
+		httpres.Header = c.res.Header.Clone()
+		httpres.Request = req.Request
+		res.Response = &httpres
+	}
+
+	if !res.BodyClosed && res.Response != nil {
+		res.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+	}
+
+	return &res
+}
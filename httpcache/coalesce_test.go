@@ -0,0 +1,243 @@
+package httpcache
+
+import (
+	"context"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	requests := 0
+	release := make(chan struct{})
+	srv, cli := server(NewMemoryCache(), func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		<-release
+		pathHandler(w, r)
+	})
+	defer srv.Close()
+
+	coalescer := NewCoalescer()
+	cli.Use(coalescer.Middleware)
+
+	responses := make([]*sawyer.Response, 5)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := cli.NewRequest("/a")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			responses[i] = req.Get()
+		}(i)
+	}
+
+	waitForCoalesced(coalescer, 4, t)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, requests)
+
+	attempts := 0
+	for _, res := range responses {
+		assert.Equal(t, false, res.AnyError())
+		attempts += res.Attempts
+
+		value := &HttpCacheTestValue{}
+		assert.Equal(t, nil, res.Decode(value))
+		assert.Equal(t, "/a", value.Name)
+	}
+	assert.Equal(t, 1, attempts)
+
+	// the response was cached once by the shared request
+	getPath(cli, "/a", t)
+	assert.Equal(t, 1, requests)
+}
+
+func TestCoalescerPartitionsByCredentials(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+	arrived := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests += 1
+		if requests == 2 {
+			close(arrived)
+		}
+		mutex.Unlock()
+
+		// hold each request until both are in flight
+		select {
+		case <-arrived:
+		case <-time.After(time.Second):
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"secret for ` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer srv.Close()
+
+	coalescer := NewCoalescer()
+	names := []string{"alice", "bob"}
+	values := make([]*HttpCacheTestValue, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		cli, err := sawyer.NewFromString(srv.URL, nil)
+		assert.Equal(t, nil, err)
+		cli.Authenticator = sawyer.BearerToken(name)
+		cli.Use(coalescer.Middleware)
+
+		wg.Add(1)
+		go func(i int, cli *sawyer.Client) {
+			defer wg.Done()
+			req, err := cli.NewRequest("/user")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			values[i] = &HttpCacheTestValue{}
+			if err := req.Get().Decode(values[i]); err != nil {
+				t.Error(err)
+			}
+		}(i, cli)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, uint64(0), coalescer.Coalesced())
+	assert.Equal(t, "secret for Bearer alice", values[0].Name)
+	assert.Equal(t, "secret for Bearer bob", values[1].Name)
+}
+
+func TestCoalescerWaiterCanceled(t *testing.T) {
+	release := make(chan struct{})
+	srv, cli := server(NewMemoryCache(), func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		pathHandler(w, r)
+	})
+	defer srv.Close()
+
+	coalescer := NewCoalescer()
+	cli.Use(coalescer.Middleware)
+
+	done := make(chan *sawyer.Response)
+	go func() {
+		req, _ := cli.NewRequest("/a")
+		done <- req.Get()
+	}()
+
+	waitForCall(coalescer, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := cli.NewRequest("/a")
+	assert.Equal(t, nil, err)
+	waiter := req.GetContext(ctx)
+	assert.Equal(t, true, waiter.IsCanceled())
+
+	close(release)
+	res := <-done
+	assert.Equal(t, false, res.AnyError())
+}
+
+func TestCoalescerPanic(t *testing.T) {
+	srv, cli := server(NewMemoryCache(), pathHandler)
+	defer srv.Close()
+
+	release := make(chan struct{})
+	panics := true
+	coalescer := NewCoalescer()
+	cli.Use(coalescer.Middleware)
+	cli.Use(func(req *sawyer.Request, next sawyer.HandlerFunc) *sawyer.Response {
+		if panics {
+			<-release
+			panic("boom")
+		}
+		return next(req)
+	})
+
+	recovered := make(chan interface{})
+	go func() {
+		defer func() { recovered <- recover() }()
+		req, _ := cli.NewRequest("/a")
+		req.Get()
+	}()
+
+	waitForCall(coalescer, t)
+
+	waiter := make(chan *sawyer.Response)
+	go func() {
+		req, _ := cli.NewRequest("/a")
+		waiter <- req.Get()
+	}()
+
+	waitForCoalesced(coalescer, 1, t)
+	close(release)
+	assert.Equal(t, "boom", <-recovered)
+
+	res := <-waiter
+	assert.Equal(t, true, res.IsError())
+	assert.Equal(t, "Coalesced request panicked: boom", res.Error())
+
+	// the call is no longer in flight
+	panics = false
+	getPath(cli, "/a", t)
+	assert.Equal(t, uint64(1), coalescer.Coalesced())
+}
+
+func TestCoalescerSkipsUnsafeMethods(t *testing.T) {
+	requests := 0
+	srv, cli := server(NewMemoryCache(), func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(201)
+	})
+	defer srv.Close()
+
+	coalescer := NewCoalescer()
+	cli.Use(coalescer.Middleware)
+
+	for i := 0; i < 2; i++ {
+		req, err := cli.NewRequest("/a")
+		assert.Equal(t, nil, err)
+		res := req.Post()
+		assert.Equal(t, 201, res.StatusCode)
+		ioutil.ReadAll(res.Body)
+	}
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, uint64(0), coalescer.Coalesced())
+}
+
+func waitForCoalesced(coalescer *Coalescer, count uint64, t *testing.T) {
+	for i := 0; i < 200; i++ {
+		if coalescer.Coalesced() >= count {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d coalesced requests, got %d", count, coalescer.Coalesced())
+}
+
+func waitForCall(coalescer *Coalescer, t *testing.T) {
+	for i := 0; i < 200; i++ {
+		coalescer.mutex.Lock()
+		calls := len(coalescer.calls)
+		coalescer.mutex.Unlock()
+		if calls > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Expected a request in flight")
+}
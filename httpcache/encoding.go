@@ -2,21 +2,25 @@ package httpcache
 
 import (
 	"bytes"
-	"encoding/gob"
 	"github.com/lostisland/go-sawyer"
 	"github.com/lostisland/go-sawyer/mediatype"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // Encode will create a CachedResponse from the sawyer Response, and encode it
-// to the given writer.
+// to the given writer.  The expiration is set from the response's
+// Cache-Control header, for a private cache.
 func Encode(res *sawyer.Response, writer io.Writer) error {
-	resCopy := CachedResponse{
-		Expires:          expiration(res.Response),
+	return EncodeResponse(newCachedResponse(res.Request, res, false), writer)
+}
+
+// newCachedResponse creates a CachedResponse from the sawyer Response.  The
+// Vary header fields are taken from the given request, if any.
+func newCachedResponse(req *http.Request, res *sawyer.Response, shared bool) *CachedResponse {
+	cached := &CachedResponse{
 		Status:           res.Status,
 		StatusCode:       res.StatusCode,
 		Proto:            res.Proto,
@@ -29,15 +33,21 @@ func Encode(res *sawyer.Response, writer io.Writer) error {
 	}
 
 	if res.MediaType != nil {
-		resCopy.MediaType = *res.MediaType
+		cached.MediaType = *res.MediaType
+	}
+
+	if req != nil {
+		cached.setVary(req, res.Header)
 	}
 
-	return EncodeResponse(&resCopy, writer)
+	cached.setFreshness(res.Header, shared)
+	return cached
 }
 
-// EncodeResponse encodes the CachedResponse to the given writer.
+// EncodeResponse encodes the CachedResponse to the given writer, in a versioned
+// envelope.
 func EncodeResponse(cached *CachedResponse, writer io.Writer) error {
-	return gob.NewEncoder(writer).Encode(cached)
+	return encodeResponse(cached, writer)
 }
 
 // EncodeBody copies the response's Body to the given writer.
@@ -58,11 +68,16 @@ func EncodeBody(res *sawyer.Response, bodyWriter io.Writer) error {
 
 // Decode decodes the CachedResponse from the given reader.  It is then wrapped
 // by a CachedResponseDecoder that is able to turn the CachedResponse data
-// to a sawyer Response.
+// to a sawyer Response.  Responses encoded with encoding/gob by older versions
+// are decoded too.
 func Decode(reader io.Reader) (*CachedResponseDecoder, error) {
-	dec := gob.NewDecoder(reader)
-	res := &CachedResponse{}
-	if err := dec.Decode(&res); err != nil {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := decodeResponse(data)
+	if err != nil {
 		return nil, err
 	}
 
@@ -72,8 +87,14 @@ func Decode(reader io.Reader) (*CachedResponseDecoder, error) {
 var DefaultExpirationDuration = time.Hour
 
 // CachedResponse is an http.Response that can be encoded and decoded safely.
+// ResponseTime is when the response was stored, and Age is its age at that
+// time, as reported by any intermediary caches.  Expires is when the response
+// becomes stale.  VaryFields are the header fields named by the response's Vary
+// header, and VaryHeader has their values from the original request.
 type CachedResponse struct {
 	Expires          time.Time
+	ResponseTime     time.Time
+	Age              time.Duration
 	Status           string // e.g. "200 OK"
 	StatusCode       int    // e.g. 200
 	Proto            string // e.g. "HTTP/1.0"
@@ -84,6 +105,8 @@ type CachedResponse struct {
 	TransferEncoding []string
 	Trailer          http.Header
 	MediaType        mediatype.MediaType
+	VaryFields       []string
+	VaryHeader       http.Header
 }
 
 // CachedResponseDecoder can decode the embedded CachedResponse into a sawyer
@@ -91,6 +114,7 @@ type CachedResponse struct {
 type CachedResponseDecoder struct {
 	Cacher      sawyer.Cacher
 	SetBodyFunc func(res *sawyer.Response)
+	revalidate  bool
 	*CachedResponse
 }
 
@@ -128,7 +152,52 @@ func (r *CachedResponseDecoder) Decode(req *sawyer.Request) *sawyer.Response {
 
 // IsExpired returns true if the CachedResponse needs to be refreshed.
 func (r *CachedResponseDecoder) IsExpired() bool {
-	return time.Now().After(r.Expires)
+	return r.revalidate || time.Now().After(r.Expires)
+}
+
+// MustRevalidate returns true if the CachedResponse can't be used without
+// revalidation once it expires.
+func (r *CachedResponseDecoder) MustRevalidate() bool {
+	cc := ParseCacheControl(r.Header)
+	return cc.MustRevalidate() || cc.NoCache()
+}
+
+// StaleWhileRevalidate returns true if the expired response is within its
+// "stale-while-revalidate" window, so it can be served while it is refreshed.
+func (r *CachedResponseDecoder) StaleWhileRevalidate() bool {
+	return r.servableStale(ParseCacheControl(r.Header).StaleWhileRevalidate)
+}
+
+// StaleIfError returns true if the expired response is within its
+// "stale-if-error" window, so it can be served if the request fails.
+func (r *CachedResponseDecoder) StaleIfError() bool {
+	return r.servableStale(ParseCacheControl(r.Header).StaleIfError)
+}
+
+// servableStale checks if the response expired less than the given window ago.
+// Responses that must be revalidated, or requests that ask for revalidation,
+// are never served stale.
+func (r *CachedResponseDecoder) servableStale(window func() (time.Duration, bool)) bool {
+	if r.revalidate || r.MustRevalidate() {
+		return false
+	}
+
+	d, ok := window()
+	return ok && time.Now().Before(r.Expires.Add(d))
+}
+
+// checkRequest forces revalidation if the request asks for it with the
+// "no-cache" or "max-age=0" directives.  A fresh response with the immutable
+// directive won't change, so it's served without revalidating.
+func (r *CachedResponseDecoder) checkRequest(req *http.Request) {
+	if ParseCacheControl(r.Header).Immutable() && time.Now().Before(r.Expires) {
+		return
+	}
+
+	cc := ParseCacheControl(req.Header)
+	if maxAge, ok := cc.MaxAge(); cc.NoCache() || (ok && maxAge == 0) {
+		r.revalidate = true
+	}
 }
 
 // IsFresh returns true if the CachedResponse does not need to be refreshed.
@@ -136,8 +205,14 @@ func (r *CachedResponseDecoder) IsFresh() bool {
 	return !r.IsExpired()
 }
 
-// SetupRequest passes the cached ETag and Last Modified date to the request.
+// SetupRequest passes the cached ETag and Last Modified date to the request,
+// which revalidates the cached response.  It is counted in the Cacher's
+// Stats.Revalidations.
 func (r *CachedResponseDecoder) SetupRequest(req *http.Request) {
+	if cacher, ok := r.Cacher.(*Cacher); ok {
+		cacher.record(func(s *Stats) { s.Revalidations += 1 })
+	}
+
 	if etag := r.Header.Get(etagHeader); len(etag) > 0 {
 		req.Header.Set(ifNoneMatchHeader, etag)
 	}
@@ -147,24 +222,8 @@ func (r *CachedResponseDecoder) SetupRequest(req *http.Request) {
 	}
 }
 
-func expiration(res *http.Response) time.Time {
-	return time.Now().Add(maxAgeDuration(res.Header.Get("Cache-Control")))
-}
-
 func maxAgeDuration(header string) time.Duration {
-	if len(header) > 0 {
-		for _, field := range strings.Fields(header) {
-			pieces := strings.SplitN(field, "=", 2)
-			if len(pieces) != 2 || pieces[0] != "max-age" {
-				continue
-			}
-			if dur, err := time.ParseDuration(pieces[1] + "s"); err == nil {
-				return dur
-			}
-		}
-	}
-
-	return DefaultExpirationDuration
+	return freshnessLifetime(http.Header{cacheControlHeader: {header}}, false)
 }
 
 const (
@@ -58,6 +58,14 @@ func TestMaxAgeValueDuration(t *testing.T) {
 	assert.Equal(t, time.Minute, maxAgeDuration("max-age=60"))
 }
 
+func TestMaxAgeWithOtherDirectivesDuration(t *testing.T) {
+	assert.Equal(t, time.Minute, maxAgeDuration("max-age=60, private"))
+}
+
+func TestNoCacheDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), maxAgeDuration("no-cache"))
+}
+
 type SetupServer struct {
 	Client *sawyer.Client
 	Server *httptest.Server
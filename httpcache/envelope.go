@@ -0,0 +1,279 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/lostisland/go-sawyer/hypermedia"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Cached responses and Cacher entries are encoded in a versioned envelope, so
+// that the format can change without breaking existing caches:
+//
+//	magic    4 bytes  "SWCR" for a CachedResponse, "SWCE" for a Cacher entry
+//	version  1 byte   the envelope version, currently 1
+//	length   4 bytes  big-endian length of the JSON header
+//	header   JSON     a response or entry document, described below
+//	bodies   bytes    entries only: the variant bodies, one after another
+//
+// A response document has the fields of a CachedResponse:
+//
+//	{"status": "200 OK", "status_code": 200, "proto": "HTTP/1.1",
+//	 "proto_major": 1, "proto_minor": 1, "header": {...},
+//	 "content_length": 13, "transfer_encoding": [...], "trailer": {...},
+//	 "media_type": "application/json", "vary_fields": [...],
+//	 "vary_header": {...}, "expires": "<RFC 3339>",
+//	 "response_time": "<RFC 3339>", "age_ns": 0}
+//
+// An entry document has the cache key, the request URL, the cached relations,
+// and the variants.  Each variant has the size and hex encoded sha256 of its stored
+// body, which is checked when the entry is decoded, and the names of the
+// BodyTransforms applied to the body:
+//
+//	{"cache_key": "...", "url": "https://...", "rels": {"self": "https://..."},
+//	 "variants": [{"key": "...", "response": {...},
+//	               "body_size": 13, "body_sha256": "...",
+//	               "transforms": ["gzip", "aes-gcm"]}]}
+//
+// Older versions of FileCache stored gob encoded CachedResponses in their own
+// layout, which Cacher.Migrate() rewrites in the current format.  Decode() still
+// reads a gob encoded CachedResponse.
+const (
+	responseMagic   = "SWCR"
+	entryMagic      = "SWCE"
+	envelopeVersion = 1
+	envelopeSize    = len(responseMagic) + 1 + 4
+)
+
+// CorruptEntryError is returned when a cached value can't be decoded, or a body
+// does not match its checksum.  Use errors.Is to check for it.
+var CorruptEntryError = errors.New("Corrupt cache entry")
+
+var (
+	errNoEnvelope      = errors.New("No cache envelope")
+	errEnvelopeVersion = errors.New("Unsupported cache envelope version")
+)
+
+type responseDocument struct {
+	Status           string      `json:"status"`
+	StatusCode       int         `json:"status_code"`
+	Proto            string      `json:"proto"`
+	ProtoMajor       int         `json:"proto_major"`
+	ProtoMinor       int         `json:"proto_minor"`
+	Header           http.Header `json:"header,omitempty"`
+	ContentLength    int64       `json:"content_length"`
+	TransferEncoding []string    `json:"transfer_encoding,omitempty"`
+	Trailer          http.Header `json:"trailer,omitempty"`
+	MediaType        string      `json:"media_type,omitempty"`
+	VaryFields       []string    `json:"vary_fields,omitempty"`
+	VaryHeader       http.Header `json:"vary_header,omitempty"`
+	Expires          time.Time   `json:"expires"`
+	ResponseTime     time.Time   `json:"response_time"`
+	AgeNs            int64       `json:"age_ns"`
+}
+
+type entryDocument struct {
+	Key       string               `json:"cache_key,omitempty"`
+	URL       string               `json:"url"`
+	Relations hypermedia.Relations `json:"rels,omitempty"`
+	Variants  []*variantDocument   `json:"variants"`
+}
+
+type variantDocument struct {
+	Key        string            `json:"key"`
+	Response   *responseDocument `json:"response"`
+	BodySize   int64             `json:"body_size"`
+	BodySha256 string            `json:"body_sha256"`
+	Transforms []string          `json:"transforms,omitempty"`
+}
+
+func newResponseDocument(r *CachedResponse) *responseDocument {
+	return &responseDocument{
+		Status:           r.Status,
+		StatusCode:       r.StatusCode,
+		Proto:            r.Proto,
+		ProtoMajor:       r.ProtoMajor,
+		ProtoMinor:       r.ProtoMinor,
+		Header:           r.Header,
+		ContentLength:    r.ContentLength,
+		TransferEncoding: r.TransferEncoding,
+		Trailer:          r.Trailer,
+		MediaType:        r.MediaType.String(),
+		VaryFields:       r.VaryFields,
+		VaryHeader:       r.VaryHeader,
+		Expires:          r.Expires,
+		ResponseTime:     r.ResponseTime,
+		AgeNs:            int64(r.Age),
+	}
+}
+
+func (d *responseDocument) CachedResponse() *CachedResponse {
+	r := &CachedResponse{
+		Expires:          d.Expires,
+		ResponseTime:     d.ResponseTime,
+		Age:              time.Duration(d.AgeNs),
+		Status:           d.Status,
+		StatusCode:       d.StatusCode,
+		Proto:            d.Proto,
+		ProtoMajor:       d.ProtoMajor,
+		ProtoMinor:       d.ProtoMinor,
+		Header:           d.Header,
+		ContentLength:    d.ContentLength,
+		TransferEncoding: d.TransferEncoding,
+		Trailer:          d.Trailer,
+		VaryFields:       d.VaryFields,
+		VaryHeader:       d.VaryHeader,
+	}
+
+	if len(d.MediaType) > 0 {
+		if mt, err := mediatype.Parse(d.MediaType); err == nil {
+			r.MediaType = *mt
+		}
+	}
+
+	return r
+}
+
+// encodeResponse writes the CachedResponse in a response envelope.
+func encodeResponse(r *CachedResponse, w io.Writer) error {
+	return writeEnvelope(w, responseMagic, newResponseDocument(r))
+}
+
+// decodeResponse reads a CachedResponse from a response envelope, or from a
+// gob encoded value written by an older version.
+func decodeResponse(data []byte) (*CachedResponse, error) {
+	doc := &responseDocument{}
+	_, err := readEnvelope(data, responseMagic, doc)
+	if err == nil {
+		return doc.CachedResponse(), nil
+	}
+	if err != errNoEnvelope {
+		return nil, err
+	}
+
+	r := &CachedResponse{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+		return nil, CorruptEntryError
+	}
+	return r, nil
+}
+
+// encodeEntry returns the Store value for the entry, in an entry envelope.
+func encodeEntry(entry *storedEntry) ([]byte, error) {
+	doc := &entryDocument{Key: entry.Key, URL: entry.URL, Relations: entry.Relations}
+	for _, variant := range entry.Variants {
+		sum := sha256.Sum256(variant.Body)
+		doc.Variants = append(doc.Variants, &variantDocument{
+			Key:        variant.Key,
+			Response:   newResponseDocument(variant.Response),
+			BodySize:   int64(len(variant.Body)),
+			BodySha256: hex.EncodeToString(sum[:]),
+			Transforms: variant.Transforms,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeEnvelope(buf, entryMagic, doc); err != nil {
+		return nil, err
+	}
+
+	for _, variant := range entry.Variants {
+		buf.Write(variant.Body)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeEntry decodes a Store value.  A value that isn't an entry envelope is
+// corrupt.
+func decodeEntry(value []byte) (*storedEntry, error) {
+	doc := &entryDocument{}
+	bodies, err := readEnvelope(value, entryMagic, doc)
+	if err == errNoEnvelope {
+		return nil, CorruptEntryError
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &storedEntry{Key: doc.Key, URL: doc.URL, Relations: doc.Relations}
+	for _, variant := range doc.Variants {
+		if variant.Response == nil || variant.BodySize < 0 || variant.BodySize > int64(len(bodies)) {
+			return nil, CorruptEntryError
+		}
+
+		body := bodies[:variant.BodySize]
+		bodies = bodies[variant.BodySize:]
+
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != variant.BodySha256 {
+			return nil, CorruptEntryError
+		}
+
+		entry.Variants = append(entry.Variants, &storedVariant{
+			Key:        variant.Key,
+			Response:   variant.Response.CachedResponse(),
+			Body:       body,
+			Transforms: variant.Transforms,
+		})
+	}
+
+	if len(bodies) > 0 {
+		return nil, CorruptEntryError
+	}
+
+	return entry, nil
+}
+
+func writeEnvelope(w io.Writer, magic string, doc interface{}) error {
+	header, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, envelopeSize)
+	copy(prefix, magic)
+	prefix[len(magic)] = envelopeVersion
+	binary.BigEndian.PutUint32(prefix[len(magic)+1:], uint32(len(header)))
+
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err = w.Write(header)
+	return err
+}
+
+// readEnvelope decodes the JSON header of the envelope into doc, and returns
+// the data after it.  It returns errNoEnvelope if the data does not start with
+// the magic, and errEnvelopeVersion if it was written by a newer version.
+func readEnvelope(data []byte, magic string, doc interface{}) ([]byte, error) {
+	if len(data) < envelopeSize || string(data[:len(magic)]) != magic {
+		return nil, errNoEnvelope
+	}
+
+	if version := data[len(magic)]; version != envelopeVersion {
+		if version > envelopeVersion {
+			return nil, errEnvelopeVersion
+		}
+		return nil, CorruptEntryError
+	}
+
+	length := int64(binary.BigEndian.Uint32(data[len(magic)+1:]))
+	data = data[envelopeSize:]
+	if length > int64(len(data)) {
+		return nil, CorruptEntryError
+	}
+
+	if err := json.Unmarshal(data[:length], doc); err != nil {
+		return nil, CorruptEntryError
+	}
+
+	return data[length:], nil
+}
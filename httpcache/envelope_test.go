@@ -0,0 +1,123 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseEnvelope(t *testing.T) {
+	mt, err := mediatype.Parse("application/vnd.sawyer+json; charset=utf-8")
+	assert.Equal(t, nil, err)
+
+	orig := testCachedResponse()
+	orig.MediaType = *mt
+
+	var buf bytes.Buffer
+	assert.Equal(t, nil, EncodeResponse(orig, &buf))
+	assert.Equal(t, "SWCR\x01", buf.String()[:5])
+
+	decoded, err := Decode(&buf)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, decoded.StatusCode)
+	assert.Equal(t, "bar", decoded.Header.Get("Foo"))
+	assert.Equal(t, orig.Age, decoded.Age)
+	assert.Equal(t, true, orig.Expires.Equal(decoded.Expires))
+	assert.Equal(t, []string{"Accept"}, decoded.VaryFields)
+	assert.Equal(t, "json", decoded.MediaType.Format)
+	assert.Equal(t, "utf-8", decoded.MediaType.Params["charset"])
+}
+
+func TestDecodeGobResponse(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, nil, gob.NewEncoder(&buf).Encode(testCachedResponse()))
+
+	decoded, err := Decode(&buf)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, decoded.StatusCode)
+	assert.Equal(t, "bar", decoded.Header.Get("Foo"))
+}
+
+func TestDecodeCorruptResponse(t *testing.T) {
+	_, err := Decode(bytes.NewBufferString("SWCR\x01\x00\x00\x00\x05{"))
+	assert.Equal(t, true, errors.Is(err, CorruptEntryError))
+}
+
+func TestCorruptEntries(t *testing.T) {
+	corrupt := map[string]func([]byte) []byte{
+		"body": func(value []byte) []byte {
+			value[len(value)-2] ^= 0xff
+			return value
+		},
+		"truncated": func(value []byte) []byte {
+			return value[:len(value)/2]
+		},
+		"garbage": func(value []byte) []byte {
+			return []byte("not a cache entry")
+		},
+	}
+
+	for name, fn := range corrupt {
+		store := NewMemoryStore()
+		cache := NewCacher(store)
+		srv, cli := server(cache, pathHandler)
+
+		req := getPath(cli, "/a", t)
+		key := cache.key(req.Request)
+		value, ok, _ := store.Get(key)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, nil, store.Put(key, fn(append([]byte{}, value...))))
+
+		_, err := cache.Get(req.Request)
+		assert.Equalf(t, NoResponseError, err, "corrupt %s", name)
+
+		_, ok, _ = store.Get(key)
+		assert.Equalf(t, false, ok, "corrupt %s was not deleted", name)
+
+		// the next request stores a fresh entry
+		getPath(cli, "/a", t)
+		assertMemoryCached(cache, cli, "/a", true, t)
+		srv.Close()
+	}
+}
+
+func TestNewerEntryVersion(t *testing.T) {
+	store := NewMemoryStore()
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	req := getPath(cli, "/a", t)
+	key := cache.key(req.Request)
+	value, _, _ := store.Get(key)
+	value = append([]byte{}, value...)
+	value[len(entryMagic)] = envelopeVersion + 1
+	assert.Equal(t, nil, store.Put(key, value))
+
+	// entries from a newer version are a miss, but are not deleted
+	_, err := cache.Get(req.Request)
+	assert.Equal(t, NoResponseError, err)
+	_, ok, _ := store.Get(key)
+	assert.Equal(t, true, ok)
+}
+
+func testCachedResponse() *CachedResponse {
+	return &CachedResponse{
+		Expires:      time.Now().Add(time.Minute).Round(0),
+		ResponseTime: time.Now().Round(0),
+		Age:          1500 * time.Millisecond,
+		Status:       "200 OK",
+		StatusCode:   200,
+		Proto:        "HTTP/1.1",
+		ProtoMajor:   1,
+		ProtoMinor:   1,
+		Header:       http.Header{"Foo": {"bar"}},
+		VaryFields:   []string{"Accept"},
+		VaryHeader:   http.Header{"Accept": {"application/json"}},
+	}
+}
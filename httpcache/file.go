@@ -1,211 +1,225 @@
 package httpcache
 
 import (
-	"encoding/gob"
 	"github.com/lostisland/go-sawyer"
-	"github.com/lostisland/go-sawyer/hypermedia"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 const (
-	keyFilename      = "key"
-	responseFilename = "response"
-	bodyFilename     = "body"
-	relsFilename     = "rels"
-	fileCreateFlag   = os.O_RDWR | os.O_CREATE | os.O_EXCL
+	keyFilename   = "key"
+	valueFilename = "value"
+	tempPrefix    = "tmp_"
+	lockFilename  = "lock"
 )
 
-// FileCache is a sawyer.Cacher that stores entries on disk.
+// FileCache is a Cacher that keeps its entries in a FileStore.  It keeps the
+// API of older versions, where the cache was a single type: the Cacher's
+// fields, like KeyFunc and Shared, and the FileStore's limits and methods, like
+// MaxEntries and GC(), are promoted.
 type FileCache struct {
-	path string
+	*Cacher
+	*FileStore
 }
 
+// NewFileCache returns a FileCache with a new FileStore at the given path.
 func NewFileCache(path string) *FileCache {
-	return &FileCache{path}
+	store := NewFileStore(path)
+	return &FileCache{NewCacher(store), store}
 }
 
+// Get implements the sawyer.Cacher interface.
 func (c *FileCache) Get(req *http.Request) (sawyer.CachedResponse, error) {
-	path := c.requestPath(req)
+	return c.Cacher.Get(req)
+}
+
+// FileStore is a Store that keeps the values on disk.  Each key has a directory
+// with the key and value files.
+//
+// MaxEntries, MaxBytes and MaxAge limit the size of the store directory.  They
+// are enforced by GC(), which can run periodically with StartJanitor().  A zero
+// value disables each limit.
+//
+// Writes are committed atomically, and the store directory is guarded by an
+// advisory file lock, so several processes can share it safely.  Update()
+// changes a value under the exclusive lock.
+type FileStore struct {
+	MaxEntries int
+	MaxBytes   int64
+	MaxAge     time.Duration
+	path       string
+	mutex      sync.RWMutex
+	statsMutex sync.Mutex
+	evictions  uint64
+}
 
-	responseFile, err := os.Open(filepath.Join(path, responseFilename))
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Get(key string) ([]byte, bool, error) {
+	lock, err := s.lock(false)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	defer responseFile.Close()
+	defer lock.Unlock()
 
-	cachedResponse, err := Decode(responseFile)
-	if err == nil {
-		cachedResponse.Cacher = c
-		cachedResponse.SetBodyFunc = func(res *sawyer.Response) {
-			bodyFile, err := os.Open(filepath.Join(path, bodyFilename))
-			if err == nil {
-				res.Body = bodyFile
-				res.BodyClosed = false
-			} else {
-				res.ResponseError = err
-				res.BodyClosed = true
-			}
-		}
+	path := s.keyPath(key)
+	value, err := ioutil.ReadFile(filepath.Join(path, valueFilename))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
 	}
 
-	return cachedResponse, err
+	s.touch(path)
+	return value, true, nil
 }
 
-func (c *FileCache) Set(req *http.Request, res *sawyer.Response) error {
-	path := c.requestPath(req)
-	if err := os.MkdirAll(path, 0755); err != nil {
-		return err
-	}
-
-	keyFile, err := newTempFile(path, keyFilename)
+// Put writes the key and value to a new temp directory, which then replaces the
+// key's directory in a single step.
+func (s *FileStore) Put(key string, value []byte) error {
+	temp, err := s.writeTemp(key, value)
 	if err != nil {
 		return err
 	}
-	defer keyFile.Close()
-	keyFile.Write([]byte(RequestKey(req)))
+	defer os.RemoveAll(temp)
 
-	responseFile, err := newTempFile(path, responseFilename)
+	lock, err := s.lock(true)
 	if err != nil {
 		return err
 	}
-	defer responseFile.Close()
+	defer lock.Unlock()
 
-	if err = Encode(res, responseFile); err != nil {
-		return err
-	}
+	return s.commit(temp, key)
+}
 
-	bodyFile, err := newTempFile(path, bodyFilename)
+// Update implements the UpdatingStore interface.  The value is read, changed
+// and written under the exclusive lock, so processes that share the store
+// directory don't undo each other's changes.
+func (s *FileStore) Update(key string, fn func(value []byte, ok bool) ([]byte, error)) error {
+	lock, err := s.lock(true)
 	if err != nil {
 		return err
 	}
-	defer bodyFile.Close()
-
-	err = EncodeBody(res, bodyFile)
-	if err == nil {
-		keyFile.Keep = true
-		responseFile.Keep = true
-		bodyFile.Keep = true
-	}
+	defer lock.Unlock()
 
-	return err
-}
-
-func (c *FileCache) Reset(req *http.Request) error {
-	path := c.requestPath(req)
-	os.Remove(filepath.Join(path, responseFilename))
-	os.Remove(filepath.Join(path, bodyFilename))
-	return nil
-}
-
-func (c *FileCache) Clear(req *http.Request) error {
-	return os.RemoveAll(c.requestPath(req))
-}
-
-func (c *FileCache) UpdateCache(req *http.Request, res *http.Response) error {
-	path := c.requestPath(req)
-	if err := os.MkdirAll(path, 0755); err != nil {
+	path := s.keyPath(key)
+	value, err := ioutil.ReadFile(filepath.Join(path, valueFilename))
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	file, err := os.Open(filepath.Join(path, responseFilename))
+	value, err = fn(value, err == nil)
 	if err != nil {
 		return err
 	}
+	if value == nil {
+		return s.removeDir(path)
+	}
 
-	cached, err := Decode(file)
-	file.Close()
-
+	temp, err := s.writeTemp(key, value)
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(temp)
 
-	cached.Expires = expiration(res)
+	return s.commit(temp, key)
+}
+
+func (s *FileStore) Delete(key string) error {
+	return s.removeEntry(s.keyPath(key))
+}
 
-	tmpFile, err := newTempFile(path, responseFilename)
+// Range calls fn with the key of each entry, from the least to the most
+// recently used.
+func (s *FileStore) Range(fn func(key string) bool) error {
+	entries, err := s.entries()
 	if err != nil {
 		return err
 	}
-	defer tmpFile.Close()
 
-	err = EncodeResponse(cached.CachedResponse, tmpFile)
-	if err == nil {
-		tmpFile.Keep = true
+	for _, entry := range entries {
+		key, err := ioutil.ReadFile(filepath.Join(entry.Path, keyFilename))
+		if err != nil {
+			continue
+		}
+
+		if !fn(string(key)) {
+			break
+		}
 	}
 
-	return err
+	return nil
 }
 
-func (c *FileCache) SetRels(req *http.Request, rels hypermedia.Relations) error {
-	path := c.requestPath(req)
-	if err := os.MkdirAll(path, 0755); err != nil {
-		return err
-	}
-	if len(rels) == 0 {
-		os.Remove(filepath.Join(path, relsFilename))
-		return nil
+// writeTemp writes the key and value to a new temp directory in the store
+// directory, which is never removed.  The entry's sha prefix directories may be
+// removed by another writer until the exclusive lock is held.
+func (s *FileStore) writeTemp(key string, value []byte) (string, error) {
+	if err := os.MkdirAll(s.path, 0755); err != nil {
+		return "", err
 	}
 
-	relsFile, err := newTempFile(path, relsFilename)
+	temp, err := ioutil.TempDir(s.path, tempPrefix)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer relsFile.Close()
 
-	enc := gob.NewEncoder(relsFile)
-	if err = enc.Encode(&rels); err == nil {
-		relsFile.Keep = true
+	err = ioutil.WriteFile(filepath.Join(temp, keyFilename), []byte(key), 0666)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(temp, valueFilename), value, 0666)
 	}
 
-	return err
-}
-
-func (c *FileCache) Rels(req *http.Request) (hypermedia.Relations, bool) {
-	path := c.requestPath(req)
-	relsFile, err := os.Open(filepath.Join(path, relsFilename))
 	if err != nil {
-		return nil, false
+		os.RemoveAll(temp)
+		return "", err
 	}
-	defer relsFile.Close()
-
-	rels := make(hypermedia.Relations)
-	dec := gob.NewDecoder(relsFile)
-	dec.Decode(&rels)
-	return rels, true
+	return temp, nil
 }
 
-func (c *FileCache) requestPath(r *http.Request) string {
-	sha := RequestSha(r)
-	return filepath.Join(c.path, sha[0:2], sha[2:4], sha)
+// commit replaces the key's directory with the temp directory.  The store must
+// be locked exclusively.
+func (s *FileStore) commit(temp, key string) error {
+	path := s.keyPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return swapDir(temp, path)
 }
 
-type tempFile struct {
-	Name     string
-	Temp     string
-	Keep     bool
-	tempFile *os.File
-	file     *os.File
+// keyPath returns the directory of the key's entry, which is named after the
+// sha256 of the key.  For a Cacher with the default KeyFunc, that's the
+// RequestSha.
+func (s *FileStore) keyPath(key string) string {
+	sha := keySha(key)
+	return filepath.Join(s.path, sha[0:2], sha[2:4], sha)
 }
 
-func newTempFile(dir string, name string) (*tempFile, error) {
-	full := filepath.Join(dir, name)
-	temp := filepath.Join(dir, "tmp_"+name)
-	file, err := os.OpenFile(temp, fileCreateFlag, 0666)
-	return &tempFile{full, temp, false, file, nil}, err
-}
+// swapDir replaces dir with the temp directory.  A directory can't be renamed
+// over another one, so any existing dir is moved out of the way first, and
+// restored if the rename fails.  The store must be locked.
+func swapDir(temp, dir string) error {
+	old, err := ioutil.TempDir(filepath.Dir(dir), tempPrefix+"old_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(old)
 
-func (f *tempFile) Close() error {
-	err := f.tempFile.Close()
+	oldDir := filepath.Join(old, filepath.Base(dir))
+	moved := os.Rename(dir, oldDir) == nil
 
-	if f.Keep && err == nil {
-		err = os.Rename(f.Temp, f.Name)
+	if err := os.Rename(temp, dir); err != nil {
+		if moved {
+			os.Rename(oldDir, dir)
+		}
+		return err
 	}
 
-	os.Remove(f.Temp)
-	return err
-}
-
-func (f *tempFile) Write(data []byte) (int, error) {
-	return f.tempFile.Write(data)
+	return nil
 }
@@ -1,9 +1,17 @@
 package httpcache
 
 import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFile(t *testing.T) {
@@ -12,9 +20,420 @@ func TestFile(t *testing.T) {
 	CacheResponsesTestFor(setup.Cache, t)
 }
 
+func TestSharedFile(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	setup.Cache.Shared = true
+	SharedCacheTestFor(setup.Cache, t)
+}
+
+func TestFileStore(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	StoreTestFor(setup.Store, t)
+	UpdatingStoreTestFor(setup.Store, t)
+}
+
+func TestFileCache(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+
+	cache := NewFileCache(setup.Path)
+	cache.Shared = true
+	cache.MaxEntries = 1
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	// entries are stored under the RequestSha
+	a := getPath(cli, "/a", t)
+	sha := RequestSha(a.Request)
+	_, err := os.Stat(filepath.Join(setup.Path, sha[0:2], sha[2:4], sha, keyFilename))
+	assert.Equal(t, nil, err)
+
+	getPath(cli, "/b", t)
+	removed, err := cache.GC()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, removed)
+	setup.AssertCached(cli, "/a", false)
+	setup.AssertCached(cli, "/b", true)
+}
+
+func TestFilePrune(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	getPath(cli, "/b", t)
+	setup.Age(a, 2*time.Hour)
+
+	removed, err := setup.Store.Prune(time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, removed)
+
+	setup.AssertCached(cli, "/a", false)
+	setup.AssertCached(cli, "/b", true)
+
+	// the empty sha prefix directories are removed too
+	_, err = os.Stat(filepath.Dir(setup.EntryPath(a.Request)))
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func TestFileRemoveUnusedKeepsUsedEntries(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	b := getPath(cli, "/b", t)
+	setup.Age(a, 2*time.Hour)
+	setup.Age(b, 2*time.Hour)
+
+	entries, err := setup.Store.entries()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(entries))
+
+	// temp files aren't part of an entry
+	temp := filepath.Join(setup.EntryPath(b.Request), tempPrefix+valueFilename)
+	assert.Equal(t, nil, ioutil.WriteFile(temp, []byte("{"), 0666))
+
+	// /a is used after the walk, but before it's removed
+	setup.Age(a, 0)
+	for _, entry := range entries {
+		removed, err := setup.Store.removeUnused(entry)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, entry.Path != setup.EntryPath(a.Request), removed)
+	}
+
+	setup.AssertCached(cli, "/a", true)
+	setup.AssertCached(cli, "/b", false)
+
+	_, err = setup.Store.removeUnused(&fileEntry{Path: setup.EntryPath(b.Request)})
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func TestFileGCMaxEntries(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	setup.Store.MaxEntries = 2
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	b := getPath(cli, "/b", t)
+	c := getPath(cli, "/c", t)
+	setup.Age(a, 3*time.Minute)
+	setup.Age(b, 2*time.Minute)
+	setup.Age(c, time.Minute)
+
+	// reading /a makes /b the least recently used
+	_, err := setup.Cache.Get(a.Request)
+	assert.Equal(t, nil, err)
+
+	removed, err := setup.Store.GC()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, removed)
+
+	stats := setup.Cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, true, stats.Bytes > 0)
+
+	setup.AssertCached(cli, "/a", true)
+	setup.AssertCached(cli, "/b", false)
+	setup.AssertCached(cli, "/c", true)
+}
+
+func TestFileGCMaxBytes(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	getPath(cli, "/b", t)
+	setup.Age(a, time.Minute)
+
+	entries, err := setup.Store.entries()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(entries))
+
+	setup.Store.MaxBytes = entries[1].Size
+	removed, err := setup.Store.GC()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, removed)
+
+	setup.AssertCached(cli, "/a", false)
+	setup.AssertCached(cli, "/b", true)
+}
+
+func TestFileGCOrphanedTempFiles(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	path := setup.EntryPath(a.Request)
+
+	orphan := filepath.Join(path, tempPrefix+valueFilename)
+	recent := filepath.Join(path, tempPrefix+keyFilename)
+	assert.Equal(t, nil, ioutil.WriteFile(orphan, []byte("{"), 0666))
+	assert.Equal(t, nil, ioutil.WriteFile(recent, []byte("{"), 0666))
+
+	old := time.Now().Add(-2 * orphanAge)
+	assert.Equal(t, nil, os.Chtimes(orphan, old, old))
+
+	// read-only walks leave the orphans alone
+	setup.Store.Bytes()
+	assert.Equal(t, nil, setup.Store.Range(func(key string) bool { return true }))
+	_, err := os.Stat(orphan)
+	assert.Equal(t, nil, err)
+
+	removed, err := setup.Store.GC()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, removed)
+
+	_, err = os.Stat(orphan)
+	assert.Equal(t, true, os.IsNotExist(err))
+	_, err = os.Stat(recent)
+	assert.Equal(t, nil, err)
+	setup.AssertCached(cli, "/a", true)
+}
+
+func TestFileJanitor(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	setup.Store.MaxAge = time.Hour
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	setup.Age(a, 2*time.Hour)
+
+	stop := setup.Store.StartJanitor(5 * time.Millisecond)
+	defer stop()
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(setup.EntryPath(a.Request)); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Janitor did not remove the expired entry")
+}
+
+func TestFileJanitorInvalidInterval(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := setup.Store.StartJanitor(interval)
+		stop()
+		stop()
+	}
+}
+
+func TestFileConcurrentWriters(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+
+	// every request is stored again, by two caches on the same directory that
+	// act like separate processes
+	srv, cli := server(setup.Cache, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + r.URL.Path + `"}`))
+	})
+	defer srv.Close()
+
+	other, err := sawyer.NewFromString(srv.URL, nil)
+	assert.Equal(t, nil, err)
+	other.Header = cli.Header
+	other.Cacher = NewFileCache(setup.Path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, c := range []*sawyer.Client{cli, other} {
+			wg.Add(1)
+			go func(c *sawyer.Client) {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					req, err := c.NewRequest("/a")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					value := &HttpCacheTestValue{}
+					if err := req.Get().Decode(value); err != nil || value.Name != "/a" {
+						t.Errorf("Bad value %#v: %v", value, err)
+					}
+
+					cached, err := c.Cacher.Get(req.Request)
+					if err != nil {
+						t.Error(err)
+						continue
+					}
+
+					res := cached.Decode(req)
+					if err := res.Decode(value); err != nil || value.Name != "/a" {
+						t.Errorf("Bad cached value %#v: %v", value, err)
+					}
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+
+	entries, err := setup.Store.entries()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(entries))
+
+	// no temp files are left behind
+	filepath.Walk(setup.Path, func(path string, info os.FileInfo, err error) error {
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			t.Errorf("Temp file left behind: %s", path)
+		}
+		return nil
+	})
+}
+
+func TestFileCorruptEntry(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	srv, cli := server(setup.Cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	path := setup.EntryPath(a.Request)
+	assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(path, valueFilename), []byte("{"), 0666))
+
+	setup.AssertCached(cli, "/a", false)
+	_, err := os.Stat(path)
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func TestFileSwapDir(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+
+	dir := filepath.Join(setup.Path, "a")
+	for _, body := range []string{"old", "new"} {
+		temp, err := ioutil.TempDir(setup.Path, tempPrefix)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(temp, valueFilename), []byte(body), 0666))
+		assert.Equal(t, nil, swapDir(temp, dir))
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, valueFilename))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "new", string(data))
+
+	files, err := ioutil.ReadDir(setup.Path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(files))
+}
+
+// testdata/legacy was written by an older version of FileCache, with an entry
+// for /user and a reset entry for /user/repos that only has relations.
+func TestFileMigrateLegacy(t *testing.T) {
+	setup := FileSetup(t)
+	defer setup.Teardown()
+	copyDir(filepath.Join("testdata", "legacy"), setup.Path, t)
+	setup.Cache.Transforms = []BodyTransform{&GzipTransform{}}
+
+	cli, err := sawyer.NewFromString("https://api.github.com/", nil)
+	assert.Equal(t, nil, err)
+
+	user, err := cli.NewRequest("user")
+	assert.Equal(t, nil, err)
+	user.Header.Set("Accept", "application/json")
+
+	repos, err := cli.NewRequest("user/repos")
+	assert.Equal(t, nil, err)
+	repos.Header.Set("Accept", "application/json")
+
+	_, err = setup.Cache.Get(user.Request)
+	assert.Equal(t, NoResponseError, err)
+
+	migrated, err := setup.Cache.Migrate()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, migrated)
+
+	cached, err := setup.Cache.Get(user.Request)
+	assert.Equal(t, nil, err)
+	res := cached.Decode(user)
+	assert.Equal(t, nil, res.ResponseError)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, `"abc"`, res.Header.Get("Etag"))
+	assert.Equal(t, "json", res.MediaType.Format)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"login":"bob"}`, string(body))
+
+	rels, ok := setup.Cache.Rels(user.Request)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "https://api.github.com/user", string(rels["self"]))
+
+	entry, err := setup.Cache.getEntry(setup.Cache.key(user.Request))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "https://api.github.com/user", entry.URL)
+	assert.Equal(t, []string{"gzip"}, entry.Variants[0].Transforms)
+
+	_, err = setup.Cache.Get(repos.Request)
+	assert.Equal(t, NoResponseError, err)
+	rels, ok = setup.Cache.Rels(repos.Request)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "https://api.github.com/user/repos", string(rels["self"]))
+
+	// the old sha directories are gone
+	paths, err := setup.Store.legacyPaths()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(paths))
+	entries, err := setup.Store.entries()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(entries))
+
+	migrated, err = setup.Cache.Migrate()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, migrated)
+}
+
+func copyDir(src, dst string, t *testing.T) {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0666)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 type fileSetup struct {
 	Path  string
-	Cache *FileCache
+	Store *FileStore
+	Cache *Cacher
 	*testing.T
 }
 
@@ -30,7 +449,8 @@ func FileSetup(t *testing.T) *fileSetup {
 		t.Fatal(err)
 	}
 
-	return &fileSetup{path, NewFileCache(path), t}
+	store := NewFileStore(path)
+	return &fileSetup{path, store, NewCacher(store), t}
 }
 
 func (s *fileSetup) Teardown() {
@@ -38,3 +458,26 @@ func (s *fileSetup) Teardown() {
 		s.Fatal(err)
 	}
 }
+
+// EntryPath returns the directory of the request's entry.
+func (s *fileSetup) EntryPath(req *http.Request) string {
+	return s.Store.keyPath(s.Cache.key(req))
+}
+
+// Age sets the last used time of the request's entry to the given duration ago.
+func (s *fileSetup) Age(req *sawyer.Request, age time.Duration) {
+	used := time.Now().Add(-age)
+	key := filepath.Join(s.EntryPath(req.Request), keyFilename)
+	if err := os.Chtimes(key, used, used); err != nil {
+		s.Fatal(err)
+	}
+}
+
+func (s *fileSetup) AssertCached(cli *sawyer.Client, path string, cached bool) {
+	req, err := cli.NewRequest(path)
+	assert.Equal(s.T, nil, err)
+	req.Method = sawyer.GetMethod
+
+	_, err = s.Cache.Get(req.Request)
+	assert.Equalf(s.T, !cached, errors.Is(err, NoResponseError), "cached %s", path)
+}
@@ -0,0 +1,348 @@
+package httpcache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// orphanAge is how old a temp file must be before GC() assumes its writer
+// crashed, and removes it.
+const orphanAge = time.Hour
+
+// Prune removes the entries that have not been used for longer than
+// olderThan.  It returns the number of removed entries.
+func (s *FileStore) Prune(olderThan time.Duration) (int, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if time.Since(entry.Used) <= olderThan {
+			break
+		}
+
+		ok, err := s.removeUnused(entry)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+
+		if ok {
+			s.evicted()
+			removed += 1
+		}
+	}
+
+	return removed, nil
+}
+
+// GC walks the store directory, removing temp files left by crashed writes, and
+// any entries that have not been used for MaxAge.  Then the least recently used
+// entries are removed until the store is within MaxEntries and MaxBytes.  It
+// returns the number of removed entries.
+func (s *FileStore) GC() (int, error) {
+	entries, orphans, err := s.walk()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.removeOrphans(orphans); err != nil {
+		return 0, err
+	}
+
+	count := len(entries)
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !s.overLimit(entry, count, size) {
+			break
+		}
+
+		walked := entry.Size
+		ok, err := s.removeUnused(entry)
+		if os.IsNotExist(err) {
+			count -= 1
+			size -= walked
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+
+		// An entry that was used since the walk is kept, and the next least
+		// recently used entry is removed instead.
+		if !ok {
+			size += entry.Size - walked
+			continue
+		}
+		s.evicted()
+
+		count -= 1
+		size -= walked
+		removed += 1
+	}
+
+	return removed, nil
+}
+
+// StartJanitor runs GC() every interval in a background goroutine, until the
+// returned function is called.  An interval that isn't positive doesn't start
+// the janitor, and the returned function does nothing.
+func (s *FileStore) StartJanitor(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.GC()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Evictions returns the number of entries removed by Prune() and GC().
+func (s *FileStore) Evictions() uint64 {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	return s.evictions
+}
+
+// Bytes returns the total size of the entries, by walking the store directory.
+func (s *FileStore) Bytes() int64 {
+	entries, _ := s.entries()
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	return size
+}
+
+func (s *FileStore) evicted() {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.evictions += 1
+}
+
+// fileEntry is an entry directory in the FileStore.  Used is when the entry was
+// last stored or read, and Size is the total size of its files.
+type fileEntry struct {
+	Path string
+	Used time.Time
+	Size int64
+}
+
+// entries walks the store directory, and returns the entries from the least to
+// the most recently used.
+func (s *FileStore) entries() ([]*fileEntry, error) {
+	entries, _, err := s.walk()
+	return entries, err
+}
+
+// walk returns the entries from the least to the most recently used, and the
+// paths of the temp files and directories that are old enough to be orphans.
+// Nothing is removed, so it's safe without the exclusive lock.
+func (s *FileStore) walk() ([]*fileEntry, []string, error) {
+	var entries []*fileEntry
+	var orphans []string
+	var current *fileEntry
+
+	err := filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(s.path, path)
+		if err != nil {
+			return err
+		}
+		depth := len(strings.Split(rel, string(filepath.Separator)))
+
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			if time.Since(info.ModTime()) > orphanAge {
+				orphans = append(orphans, path)
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if depth == 3 {
+				current = &fileEntry{Path: path, Used: info.ModTime()}
+				entries = append(entries, current)
+			}
+			return nil
+		}
+
+		if current == nil || depth < 4 || !strings.HasPrefix(path, current.Path+string(filepath.Separator)) {
+			return nil
+		}
+
+		current.Size += info.Size()
+		if depth == 4 && info.Name() == keyFilename {
+			current.Used = info.ModTime()
+		}
+		return nil
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Used.Before(entries[j].Used)
+	})
+
+	return entries, orphans, err
+}
+
+// removeOrphans removes the temp files and directories left by crashed writes.
+// Each one is stat'ed again under the exclusive lock, in case it was replaced.
+func (s *FileStore) removeOrphans(orphans []string) error {
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	lock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	for _, path := range orphans {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) <= orphanAge {
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) overLimit(entry *fileEntry, count int, size int64) bool {
+	return (s.MaxAge > 0 && time.Since(entry.Used) > s.MaxAge) ||
+		(s.MaxEntries > 0 && count > s.MaxEntries) ||
+		(s.MaxBytes > 0 && size > s.MaxBytes)
+}
+
+// removeUnused removes the entry directory found by entries(), unless it was
+// used or rewritten since.  The entry is stat'ed again under the exclusive lock,
+// and updated with its current Used time and Size.  It returns false if the
+// entry was kept, and an error satisfying os.IsNotExist if it's already gone.
+func (s *FileStore) removeUnused(entry *fileEntry) (bool, error) {
+	lock, err := s.lock(true)
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	current, err := statEntry(entry.Path)
+	if err != nil {
+		return false, err
+	}
+
+	unchanged := current.Used.Equal(entry.Used) && current.Size == entry.Size
+	entry.Used = current.Used
+	entry.Size = current.Size
+	if !unchanged {
+		return false, nil
+	}
+
+	return true, s.removeDir(entry.Path)
+}
+
+// statEntry returns the current Used time and Size of the entry directory at
+// the given path, the same way entries() finds them.
+func statEntry(path string) (*fileEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &fileEntry{Path: path, Used: info.ModTime()}
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		entry.Size += info.Size()
+		if file == filepath.Join(path, keyFilename) {
+			entry.Used = info.ModTime()
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// removeEntry removes the entry directory, and its sha prefix directories if
+// they are empty.
+func (s *FileStore) removeEntry(path string) error {
+	lock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return s.removeDir(path)
+}
+
+// removeDir removes the entry directory and its empty sha prefix directories.
+// The caller must hold the exclusive lock.
+func (s *FileStore) removeDir(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	parent := filepath.Dir(path)
+	if os.Remove(parent) == nil {
+		os.Remove(filepath.Dir(parent))
+	}
+	return nil
+}
+
+// touch marks the entry at the given path as recently used.
+func (s *FileStore) touch(path string) {
+	now := time.Now()
+	os.Chtimes(filepath.Join(path, keyFilename), now, now)
+}
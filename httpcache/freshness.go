@@ -0,0 +1,96 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CurrentAge returns the current age of the cached response, as described in
+// RFC 9111, section 4.2.3.
+func (r *CachedResponse) CurrentAge() time.Duration {
+	return r.Age + time.Since(r.ResponseTime)
+}
+
+// setFreshness calculates the Age and Expires time of the response from the
+// given response header, as if the response was just received.
+func (r *CachedResponse) setFreshness(header http.Header, shared bool) {
+	r.ResponseTime = time.Now()
+	r.Age = initialAge(header, r.ResponseTime)
+	r.Expires = r.ResponseTime.Add(freshnessLifetime(header, shared) - r.Age)
+}
+
+// freshnessLifetime returns how long a response stays fresh, as described in
+// RFC 9111, section 4.2.1.  Explicit Cache-Control directives win over the
+// Expires header.  Without either, a heuristic lifetime of 10% of the time
+// since the Last-Modified date is used.  If all else fails, the response is
+// fresh for the DefaultExpirationDuration.
+func freshnessLifetime(header http.Header, shared bool) time.Duration {
+	cc := ParseCacheControl(header)
+	if cc.NoCache() {
+		return 0
+	}
+
+	if shared {
+		if sMaxAge, ok := cc.SMaxAge(); ok {
+			return sMaxAge
+		}
+	}
+
+	if maxAge, ok := cc.MaxAge(); ok {
+		return maxAge
+	}
+
+	date := dateHeader(header, time.Now())
+	if expires := header.Get(expiresHeader); len(expires) > 0 {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return nonNegative(t.Sub(date))
+	}
+
+	if lastmod, err := http.ParseTime(header.Get(lastModHeader)); err == nil {
+		return nonNegative(date.Sub(lastmod) / 10)
+	}
+
+	return DefaultExpirationDuration
+}
+
+// initialAge returns the corrected initial age of a response received at the
+// given time, as described in RFC 9111, section 4.2.3.  The request time is
+// not known, so the response delay is not accounted for.
+func initialAge(header http.Header, responseTime time.Time) time.Duration {
+	apparentAge := nonNegative(responseTime.Sub(dateHeader(header, responseTime)))
+
+	ageValue := time.Duration(0)
+	if secs, err := strconv.ParseInt(header.Get(ageHeader), 10, 64); err == nil && secs > 0 {
+		ageValue = time.Duration(secs) * time.Second
+	}
+
+	if apparentAge > ageValue {
+		return apparentAge
+	}
+	return ageValue
+}
+
+// dateHeader returns the parsed Date header, or the given fallback time.
+func dateHeader(header http.Header, fallback time.Time) time.Time {
+	if date, err := http.ParseTime(header.Get(dateHeaderName)); err == nil {
+		return date
+	}
+	return fallback
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+const (
+	ageHeader      = "Age"
+	dateHeaderName = "Date"
+	expiresHeader  = "Expires"
+)
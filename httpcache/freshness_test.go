@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFreshnessLifetimeDirectives(t *testing.T) {
+	header := http.Header{"Cache-Control": {"max-age=60, s-maxage=10"}}
+	header.Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(t, time.Minute, freshnessLifetime(header, false))
+	assert.Equal(t, 10*time.Second, freshnessLifetime(header, true))
+
+	header = http.Header{"Cache-Control": {"max-age=60, no-cache"}}
+	assert.Equal(t, time.Duration(0), freshnessLifetime(header, false))
+
+	header = http.Header{"Cache-Control": {"max-age=0"}}
+	assert.Equal(t, time.Duration(0), freshnessLifetime(header, false))
+}
+
+func TestFreshnessLifetimeExpires(t *testing.T) {
+	date := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	header := http.Header{}
+	header.Set("Date", date.Format(http.TimeFormat))
+	header.Set("Expires", date.Add(2*time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, 2*time.Hour, freshnessLifetime(header, false))
+
+	header.Set("Expires", "0")
+	assert.Equal(t, time.Duration(0), freshnessLifetime(header, false))
+}
+
+func TestFreshnessLifetimeHeuristic(t *testing.T) {
+	date := time.Now().UTC().Truncate(time.Second)
+	header := http.Header{}
+	header.Set("Date", date.Format(http.TimeFormat))
+	header.Set("Last-Modified", date.Add(-10*time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, time.Hour, freshnessLifetime(header, false))
+}
+
+func TestInitialAge(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	assert.Equal(t, time.Duration(0), initialAge(header, now))
+
+	header.Set("Age", "30")
+	assert.Equal(t, 30*time.Second, initialAge(header, now))
+
+	// apparent age from the Date header is larger
+	header.Set("Date", now.Add(-time.Minute).UTC().Format(http.TimeFormat))
+	age := initialAge(header, now)
+	assert.T(t, age >= time.Minute && age < time.Minute+time.Second, age)
+
+	// clock skew doesn't make the age negative
+	header.Set("Date", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+	header.Del("Age")
+	assert.Equal(t, time.Duration(0), initialAge(header, now))
+}
+
+func TestSetFreshness(t *testing.T) {
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=60")
+	header.Set("Age", "50")
+
+	cached := &CachedResponse{}
+	cached.setFreshness(header, false)
+	assert.Equal(t, 50*time.Second, cached.Age)
+	assert.T(t, cached.CurrentAge() >= 50*time.Second)
+
+	remaining := cached.Expires.Sub(time.Now())
+	assert.T(t, remaining > 9*time.Second && remaining <= 10*time.Second, remaining)
+}
@@ -8,22 +8,75 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/http"
+	"strings"
 )
 
-// RequestKey builds a unique string key for a net/http Request.
+// A KeyFunc builds the cache key for a net/http Request.
+type KeyFunc func(*http.Request) string
+
+// RequestKey builds a unique string key for a net/http Request.  Requests with
+// different Authorization headers get different keys, so that a cache shared
+// between clients never serves one user's response to another.
 func RequestKey(r *http.Request) string {
-	return r.Header.Get(keyHeader) + keySep + r.URL.String()
+	return defaultKeyFunc(r)
+}
+
+// PartitionedKeyFunc returns a KeyFunc that partitions the cache keys by a hash
+// of the Authorization header and the given headers, such as "Cookie".
+func PartitionedKeyFunc(headers ...string) KeyFunc {
+	partitionHeaders := append([]string{authHeader}, headers...)
+	return func(r *http.Request) string {
+		key := r.Header.Get(keyHeader) + keySep + r.URL.String()
+		if partition := headerHash(r.Header, partitionHeaders); len(partition) > 0 {
+			key = key + keySep + partition
+		}
+		return key
+	}
 }
 
+// RequestSha returns the hex encoded sha256 of the RequestKey.
+//
+// This is a breaking change.  Older versions returned the hex encoded key
+// bytes followed by the sha256 of an empty string, from
+// sha256.New().Sum([]byte(key)), instead of the sha256 of the key.  The
+// RequestKey also includes a hash of the Authorization header now, so
+// authenticated requests get a new key as well.  Cacher.Migrate() moves the
+// directories that older versions of FileCache stored under the old shas to
+// the new paths.
 func RequestSha(r *http.Request) string {
-	key := RequestKey(r)
-	sum := sha256.New().Sum([]byte(key))
-	return hex.EncodeToString(sum)
+	return keySha(RequestKey(r))
+}
+
+func keySha(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
 }
 
+// headerHash returns a hex encoded sha256 of the given header values, or an
+// empty string if none of them are set.
+func headerHash(header http.Header, names []string) string {
+	var values []string
+	for _, name := range names {
+		if value := header.Values(name); len(value) > 0 {
+			values = append(values, http.CanonicalHeaderKey(name)+"="+strings.Join(value, ","))
+		}
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+	return keySha(strings.Join(values, "\n"))
+}
+
+// NoResponseError is returned by the caches when there is no entry for a
+// request.  Use errors.Is to check for it.
 var NoResponseError = errors.New("No Response")
 
+var defaultKeyFunc = PartitionedKeyFunc()
+
 const (
-	keySep    = ":"
-	keyHeader = "Accept"
+	keySep             = ":"
+	keyHeader          = "Accept"
+	authHeader         = "Authorization"
+	cacheControlHeader = "Cache-Control"
 )
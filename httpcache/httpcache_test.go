@@ -1,6 +1,7 @@
 package httpcache
 
 import (
+	"errors"
 	"github.com/bmizerany/assert"
 	"github.com/lostisland/go-sawyer"
 	"github.com/lostisland/go-sawyer/hypermedia"
@@ -20,6 +21,19 @@ func CacheResponsesTestFor(cacher sawyer.Cacher, t *testing.T) {
 	ClearsCache(cacher, t)
 	GetSetCacheTestFor(cacher, t)
 	ETagExpirationTestFor(cacher, t)
+	NotModifiedMergesHeadersTestFor(cacher, t)
+	PartitionsCacheTestFor(cacher, t)
+	NoStoreTestFor(cacher, t)
+	RequestNoCacheTestFor(cacher, t)
+	ImmutableTestFor(cacher, t)
+	VaryTestFor(cacher, t)
+	VaryStarTestFor(cacher, t)
+	StaleWhileRevalidateTestFor(cacher, t)
+	StaleIfErrorTestFor(cacher, t)
+	MustRevalidateStaleTestFor(cacher, t)
+	OfflineTestFor(cacher, t)
+	TransportTestFor(cacher, t)
+	InvalidationTestFor(cacher, t)
 }
 
 func CacheGet(cacher sawyer.Cacher, t *testing.T) {
@@ -227,6 +241,7 @@ func GetSetCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
 	assert.Equal(t, false, ok)
 	cachedResponse, err := cli.Cacher.Get(req.Request)
 	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
 
 	// make first request
 	res := req.Get()
@@ -287,6 +302,442 @@ func GetSetCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
 	assert.Equal(t, false, ok)
 }
 
+func PartitionsCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + r.Header.Get("Authorization") + `"}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+	req.Header.Set("Authorization", "token a")
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, nil, err)
+
+	// same url and accept header, different credentials
+	req2, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+	req2.Header.Set("Authorization", "token b")
+
+	_, err = cli.Cacher.Get(req2.Request)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
+
+	value := &HttpCacheTestValue{}
+	res2 := req2.Get()
+	assert.Equal(t, nil, res2.Decode(value))
+	assert.Equal(t, "token b", value.Name)
+}
+
+func NoStoreTestFor(cacher sawyer.Cacher, t *testing.T) {
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60, no-store")
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
+}
+
+func RequestNoCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	req.Get()
+	req.Get()
+	assert.Equal(t, 1, requests)
+
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Get()
+	assert.Equal(t, 2, requests)
+}
+
+func ImmutableTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60, immutable")
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/immutable")
+	assert.Equal(t, nil, err)
+
+	req.Get()
+	assert.Equal(t, 1, requests)
+
+	req.Header.Set("Cache-Control", "no-cache")
+	res := req.Get()
+	assert.Equal(t, nil, res.ResponseError)
+	assert.Equal(t, 1, requests)
+
+	req.Header.Set("Cache-Control", "max-age=0")
+	req.Get()
+	assert.Equal(t, 1, requests)
+}
+
+func VaryTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + r.Header.Get("Accept-Language") + `"}`))
+	})
+	defer srv.Close()
+
+	for _, lang := range []string{"en", "fr", "en", "fr"} {
+		req, err := cli.NewRequest("/")
+		assert.Equal(t, nil, err)
+		req.Header.Set("Accept-Language", lang)
+
+		value := &HttpCacheTestValue{}
+		res := req.Get()
+		assert.Equal(t, nil, res.Decode(value))
+		assert.Equal(t, lang, value.Name)
+	}
+
+	assert.Equal(t, 2, requests)
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept-Language", "de")
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
+}
+
+func VaryStarTestFor(cacher sawyer.Cacher, t *testing.T) {
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "*")
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
+}
+
+func StaleWhileRevalidateTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	notifier := &notifyingCacher{cacher, make(chan bool, 1)}
+	srv, cli := server(notifier, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + strconv.Itoa(requests) + `"}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	value := &HttpCacheTestValue{}
+	res := req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "1", value.Name)
+	assert.Equal(t, false, res.Stale)
+	<-notifier.Updated
+
+	// expired, but not served stale without the client option
+	res = req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "2", value.Name)
+	assert.Equal(t, false, res.Stale)
+	<-notifier.Updated
+
+	cli.ServeStale = true
+	req, err = cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res = req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "2", value.Name)
+	assert.Equal(t, true, res.Stale)
+	assert.Equal(t, 0, res.Attempts)
+
+	// wait for the background revalidation
+	<-notifier.Updated
+	assert.Equal(t, 3, requests)
+
+	res = req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "3", value.Name)
+	assert.Equal(t, true, res.Stale)
+	<-notifier.Updated
+}
+
+func StaleIfErrorTestFor(cacher sawyer.Cacher, t *testing.T) {
+	status := 200
+	cacheControl := "max-age=0, stale-if-error=60"
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"Name":"` + strconv.Itoa(status) + `"}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	status = 503
+	res = req.Get()
+	assert.Equal(t, 503, res.StatusCode)
+	assert.Equal(t, false, res.Stale)
+
+	cli.ServeStale = true
+	req, err = cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	value := &HttpCacheTestValue{}
+	res = req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "200", value.Name)
+	assert.Equal(t, true, res.Stale)
+	assert.Equal(t, 1, res.Attempts)
+
+	srv.Close()
+	res = req.Get()
+	assert.Equal(t, false, res.IsError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, true, res.Stale)
+}
+
+func MustRevalidateStaleTestFor(cacher sawyer.Cacher, t *testing.T) {
+	status := 200
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0, must-revalidate, stale-if-error=60")
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+	cli.ServeStale = true
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	status = 503
+	res = req.Get()
+	assert.Equal(t, 503, res.StatusCode)
+	assert.Equal(t, false, res.Stale)
+}
+
+func OfflineTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0, must-revalidate")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + r.URL.Path + `"}`))
+	})
+	defer srv.Close()
+
+	getPath(cli, "/offline", t)
+	assert.Equal(t, 1, requests)
+
+	// offline requests serve expired responses, and never hit the server
+	cli.Offline = true
+	req, err := cli.NewRequest("/offline")
+	assert.Equal(t, nil, err)
+
+	value := &HttpCacheTestValue{}
+	res := req.Get()
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "/offline", value.Name)
+	assert.Equal(t, true, res.Stale)
+	assert.Equal(t, 0, res.Attempts)
+	assert.Equal(t, 1, requests)
+
+	req, err = cli.NewRequest("/offline/missing")
+	assert.Equal(t, nil, err)
+	res = req.Get()
+	var notCached *sawyer.NotCachedError
+	assert.Equal(t, true, errors.As(res.ResponseError, &notCached))
+	assert.Equal(t, true, errors.Is(res.ResponseError, NoResponseError))
+	assert.Equal(t, 1, requests)
+
+	// the expired response is only served after a network error with
+	// OfflineOnError
+	cli.Offline = false
+	srv.Close()
+	req, err = cli.NewRequest("/offline")
+	assert.Equal(t, nil, err)
+	res = req.Get()
+	var reqErr *sawyer.RequestError
+	assert.Equal(t, true, errors.As(res.ResponseError, &reqErr))
+
+	cli.OfflineOnError = true
+	req, err = cli.NewRequest("/offline")
+	assert.Equal(t, nil, err)
+	res = req.Get()
+	assert.Equal(t, false, res.IsError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, true, res.Stale)
+	assert.Equal(t, 1, res.Attempts)
+}
+
+func InvalidationTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if r.Method == sawyer.PostMethod {
+			w.Header().Set("Location", "/issues/2")
+			w.WriteHeader(201)
+			return
+		}
+		pathHandler(w, r)
+	})
+	defer srv.Close()
+	cli.Invalidate(sawyer.InvalidateOn(sawyer.PostMethod, "/issues", "/issues*"))
+
+	for _, path := range []string{"/issues", "/issues/2", "/issues/3", "/users"} {
+		getPath(cli, path, t)
+		getPath(cli, path, t)
+	}
+	assert.Equal(t, 4, requests)
+
+	req, err := cli.NewRequest("/issues")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 201, req.Post().StatusCode)
+	assert.Equal(t, 5, requests)
+
+	for _, path := range []string{"/issues", "/issues/2", "/issues/3", "/users"} {
+		getPath(cli, path, t)
+	}
+	assert.Equal(t, 8, requests)
+}
+
+func SharedCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60, "+r.URL.Query().Get("cc"))
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/?cc=private")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, true, errors.Is(err, NoResponseError))
+
+	req, err = cli.NewRequest("/?cc=public")
+	assert.Equal(t, nil, err)
+
+	res = req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+
+	_, err = cli.Cacher.Get(req.Request)
+	assert.Equal(t, nil, err)
+}
+
+func TestClientRelsAuthenticated(t *testing.T) {
+	requests := 0
+	srv, cli := server(NewMemoryCache(), func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"Resource","Url":"Link"}`))
+	})
+	defer srv.Close()
+	cli.Authenticator = sawyer.BearerToken("abc")
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+	res := req.Get()
+	assert.Equal(t, nil, res.Decode(&HttpCacheTestValue{}))
+	assert.Equal(t, 1, requests)
+
+	// the relations are found under the request's credentials
+	req, err = cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+	rels, res := cli.Rels(req, &HttpCacheTestValue{})
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, "Link", string(rels["Url"]))
+	assert.Equal(t, 1, requests)
+}
+
+func TestRequestKey(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept", "application/json")
+	assert.Equal(t, "application/json:https://api.github.com/user", RequestKey(req))
+
+	req.Header.Set("Authorization", "token a")
+	keyA := RequestKey(req)
+	req.Header.Set("Authorization", "token b")
+	keyB := RequestKey(req)
+	assert.NotEqual(t, keyA, keyB)
+	assert.Equal(t, 64, len(RequestSha(req)))
+
+	keyFunc := PartitionedKeyFunc("Cookie")
+	assert.Equal(t, keyB, keyFunc(req))
+	req.Header.Set("Cookie", "session=1")
+	assert.NotEqual(t, keyB, keyFunc(req))
+}
+
+func TestRequestSha(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept", "application/json")
+
+	// sha256 of "application/json:https://api.github.com/user"
+	assert.Equal(t, "f3b5af2791ed55f4b69bb36130709e8b7c2cd3ef4bc71b0e04f63fa6b2b0a99b", RequestSha(req))
+}
+
 func ETagExpirationTestFor(cacher sawyer.Cacher, t *testing.T) {
 	assertExpirationTestFor("ETag", "If-None-Match", `"boom"`, cacher, t)
 }
@@ -308,6 +759,7 @@ func assertExpirationTestFor(reqHeader, resHeader, headerValue string, cacher sa
 			return
 		}
 
+		head.Set("Cache-Control", "max-age=60")
 		w.WriteHeader(304)
 		w.Write([]byte(`{"Name":"Changed","Url":"Link"}`))
 	})
@@ -347,6 +799,90 @@ func assertExpirationTestFor(reqHeader, resHeader, headerValue string, cacher sa
 	assert.Equal(t, true, cached.IsFresh())
 }
 
+func NotModifiedMergesHeadersTestFor(cacher sawyer.Cacher, t *testing.T) {
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		head := w.Header()
+		if r.Header.Get("If-None-Match") != `"merge"` {
+			head.Set("Content-Type", "application/json")
+			head.Set("Cache-Control", "no-cache")
+			head.Set("ETag", `"merge"`)
+			w.WriteHeader(200)
+			w.Write([]byte(`{"Name":"Resource"}`))
+			return
+		}
+
+		// no Cache-Control, so the stored no-cache directive still applies
+		head.Set("X-Revalidated", "1")
+		w.WriteHeader(304)
+	})
+	defer srv.Close()
+
+	req, err := cli.NewRequest("/")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, nil, res.Decode(&HttpCacheTestValue{}))
+
+	res = req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 200, res.StatusCode)
+
+	value := &HttpCacheTestValue{}
+	assert.Equal(t, nil, res.Decode(value))
+	assert.Equal(t, "Resource", value.Name)
+
+	cached, err := cli.Cacher.Get(req.Request)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, cached.IsFresh())
+
+	res = cached.Decode(req)
+	assert.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+	assert.Equal(t, `"merge"`, res.Header.Get("ETag"))
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Equal(t, "1", res.Header.Get("X-Revalidated"))
+	res.Body.Close()
+}
+
+// pathHandler responds with the request path as the Name, cacheable for a
+// minute.
+func pathHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=60")
+	w.WriteHeader(200)
+	w.Write([]byte(`{"Name":"` + r.URL.Path + `"}`))
+}
+
+// getPath requests the path, and checks the decoded Name.
+func getPath(cli *sawyer.Client, path string, t *testing.T) *sawyer.Request {
+	req, err := cli.NewRequest(path)
+	assert.Equal(t, nil, err)
+
+	value := &HttpCacheTestValue{}
+	assert.Equal(t, nil, req.Get().Decode(value))
+	assert.Equal(t, path, value.Name)
+	return req
+}
+
+// notifyingCacher signals when a response is stored or updated, so tests can
+// wait for background revalidations.
+type notifyingCacher struct {
+	sawyer.Cacher
+	Updated chan bool
+}
+
+func (c *notifyingCacher) Set(req *http.Request, res *sawyer.Response) error {
+	err := c.Cacher.Set(req, res)
+	c.Updated <- true
+	return err
+}
+
+func (c *notifyingCacher) UpdateCache(req *http.Request, res *http.Response) error {
+	err := c.Cacher.UpdateCache(req, res)
+	c.Updated <- true
+	return err
+}
+
 func server(cacher sawyer.Cacher, handler http.HandlerFunc) (*httptest.Server, *sawyer.Client) {
 	srv := httptest.NewServer(handler)
 	cli, _ := sawyer.NewFromString(srv.URL, nil)
@@ -0,0 +1,199 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/lostisland/go-sawyer/hypermedia"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Older versions of FileCache kept each entry in a directory named after the
+// old RequestSha, which was the hex encoded key followed by the sha256 of
+// nothing.  The directory held the key, the gob encoded CachedResponse, the raw
+// body and the gob encoded relations in separate files.  Reset() removed the
+// response and body, and kept the relations.
+const (
+	legacyResponseFilename = "response"
+	legacyBodyFilename     = "body"
+	legacyRelsFilename     = "rels"
+)
+
+// legacyStore is a Store that can hold entries written by older versions, which
+// Cacher.Migrate() rewrites in the current format.
+type legacyStore interface {
+	// legacyPaths lists the entries written by older versions.
+	legacyPaths() ([]string, error)
+
+	// readLegacy reads the entry at the path.  It returns CorruptEntryError if
+	// the entry has no key.
+	readLegacy(path string) (*legacyEntry, error)
+
+	// removeLegacy deletes the entry at the path.
+	removeLegacy(path string) error
+}
+
+// legacyEntry is an entry written by an older version.  Response and Body are
+// nil if the entry was reset, and Relations is nil if none were set.
+type legacyEntry struct {
+	Key       string
+	Response  []byte
+	Body      []byte
+	Relations []byte
+}
+
+// storedEntry converts the legacy entry, applying the Cacher's Transforms to
+// the body.  Older versions ignored Vary, so the entry has a single variant
+// that matches any request.  It returns nil if there is nothing to keep.
+func (e *legacyEntry) storedEntry(c *Cacher) (*storedEntry, error) {
+	entry := &storedEntry{Key: e.Key, URL: e.Key}
+	if i := strings.Index(e.Key, keySep); i >= 0 {
+		entry.URL = e.Key[i+len(keySep):]
+	}
+
+	if e.Relations != nil {
+		rels := make(hypermedia.Relations)
+		if err := gob.NewDecoder(bytes.NewReader(e.Relations)).Decode(&rels); err != nil {
+			return nil, CorruptEntryError
+		}
+		entry.Relations = rels
+	}
+
+	if e.Response != nil {
+		res, err := decodeResponse(e.Response)
+		if err != nil {
+			return nil, err
+		}
+
+		vkey := variantKey(http.Header{}, res.VaryFields)
+		body, transforms, err := c.encodeBody(e.Body, transformKey(e.Key, vkey))
+		if err != nil {
+			return nil, err
+		}
+
+		entry.setVariant(&storedVariant{
+			Key:        vkey,
+			Response:   res,
+			Body:       body,
+			Transforms: transforms,
+		})
+	}
+
+	if entry.Relations == nil && len(entry.Variants) == 0 {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// migrateLegacy rewrites the entries written by older versions under their
+// keys, and removes them.  The mutex must be held.
+func (c *Cacher) migrateLegacy(store legacyStore) (int, error) {
+	paths, err := store.legacyPaths()
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, path := range paths {
+		ok, err := c.migrateLegacyEntry(store, path)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated += 1
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateLegacyEntry rewrites a single entry, and returns true if it was kept.
+// A corrupt entry, or one that has already been replaced by an entry in the
+// current format, is only removed.
+func (c *Cacher) migrateLegacyEntry(store legacyStore, path string) (bool, error) {
+	old, err := store.readLegacy(path)
+	if err == CorruptEntryError {
+		return false, store.removeLegacy(path)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	entry, err := old.storedEntry(c)
+	if err == CorruptEntryError {
+		return false, store.removeLegacy(path)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	keep := false
+	if entry != nil {
+		err = c.updateEntry(old.Key, func(current *storedEntry, err error) (*storedEntry, error) {
+			if !replaceable(err) {
+				return nil, errUnchanged
+			}
+			keep = true
+			return entry, nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return keep, store.removeLegacy(path)
+}
+
+// legacyPaths returns the entry directories that have no value file.
+func (s *FileStore) legacyPaths() ([]string, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(entry.Path, valueFilename)); os.IsNotExist(err) {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths, nil
+}
+
+func (s *FileStore) readLegacy(path string) (*legacyEntry, error) {
+	lock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	key, err := ioutil.ReadFile(filepath.Join(path, keyFilename))
+	if os.IsNotExist(err) {
+		return nil, CorruptEntryError
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &legacyEntry{Key: string(key)}
+	files := map[string]*[]byte{
+		legacyResponseFilename: &entry.Response,
+		legacyBodyFilename:     &entry.Body,
+		legacyRelsFilename:     &entry.Relations,
+	}
+
+	for name, data := range files {
+		*data, err = ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+func (s *FileStore) removeLegacy(path string) error {
+	return s.removeEntry(path)
+}
@@ -0,0 +1,60 @@
+package httpcache
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileLock is a shared or exclusive lock on a FileStore directory.  It guards
+// against goroutines with the FileStore's mutex, and against other processes
+// with an advisory lock on the directory's lock file.
+type fileLock struct {
+	file      *os.File
+	mutex     *sync.RWMutex
+	exclusive bool
+}
+
+// lock acquires the FileStore's lock.  Readers share the lock, while writers
+// hold it exclusively.
+func (s *FileStore) lock(exclusive bool) (*fileLock, error) {
+	if exclusive {
+		s.mutex.Lock()
+	} else {
+		s.mutex.RLock()
+	}
+
+	l := &fileLock{mutex: &s.mutex, exclusive: exclusive}
+	err := os.MkdirAll(s.path, 0755)
+	if err == nil {
+		l.file, err = os.OpenFile(filepath.Join(s.path, lockFilename), os.O_RDWR|os.O_CREATE, 0666)
+	}
+
+	if err == nil {
+		if err = lockFile(l.file, exclusive); err != nil {
+			l.file.Close()
+		}
+	}
+
+	if err != nil {
+		l.unlockMutex()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Unlock releases the file lock and the mutex.
+func (l *fileLock) Unlock() {
+	unlockFile(l.file)
+	l.file.Close()
+	l.unlockMutex()
+}
+
+func (l *fileLock) unlockMutex() {
+	if l.exclusive {
+		l.mutex.Unlock()
+	} else {
+		l.mutex.RUnlock()
+	}
+}
@@ -1,134 +1,196 @@
 package httpcache
 
 import (
-	"bytes"
-	"errors"
+	"container/list"
 	"github.com/lostisland/go-sawyer"
-	"github.com/lostisland/go-sawyer/hypermedia"
-	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 )
 
-// MemoryCache is a sawyer.Cacher that stores the entries in memory.  This is
-// only intended for testing, and should not be used in production.
+// MemoryCache is a Cacher that keeps its entries in a MemoryStore.  It keeps
+// the API of older versions, where the cache was a single type: the Cacher's
+// fields, like KeyFunc and Shared, and the MemoryStore's limits, like
+// MaxEntries, are promoted.
 type MemoryCache struct {
-	Cache map[string]*cacheEntry
+	*Cacher
+	*MemoryStore
 }
 
+// NewMemoryCache returns a MemoryCache with a new MemoryStore.
 func NewMemoryCache() *MemoryCache {
-	return &MemoryCache{make(map[string]*cacheEntry)}
+	store := NewMemoryStore()
+	return &MemoryCache{NewCacher(store), store}
 }
 
+// Get implements the sawyer.Cacher interface.
 func (c *MemoryCache) Get(req *http.Request) (sawyer.CachedResponse, error) {
-	if _, entry, ok := c.getEntry(req); ok {
-		return entry.Decode(c)
-	}
+	return c.Cacher.Get(req)
+}
+
+// Hits returns the number of Get calls that found a cached response.  See
+// Cacher.Stats.
+func (c *MemoryCache) Hits() uint64 {
+	return c.Stats().Hits
+}
+
+// Misses returns the number of Get calls that did not find a cached response.
+// See Cacher.Stats.
+func (c *MemoryCache) Misses() uint64 {
+	return c.Stats().Misses
+}
+
+// MemoryStore is a Store that keeps the values in memory.  It is safe for
+// concurrent use by multiple goroutines.
+//
+// The least recently used values are evicted once the store holds more than
+// MaxEntries values, or more than MaxBytes in total.  Values are also evicted
+// TTL after they were stored.  A zero value disables each limit.
+type MemoryStore struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	bytes      int64
+	evictions  uint64
+}
 
-	return nil, NoResponseError
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
 }
 
-func (c *MemoryCache) Set(req *http.Request, res *sawyer.Response) error {
-	key := RequestKey(req)
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	bodyBuffer := &bytes.Buffer{}
-	if err := EncodeBody(res, bodyBuffer); err != nil {
-		return err
+	entry, ok := s.getEntry(key)
+	if !ok {
+		return nil, false, nil
 	}
+	return entry.Value, true, nil
+}
 
-	resBuffer := &bytes.Buffer{}
-	if err := Encode(res, resBuffer); err != nil {
-		return err
+func (s *MemoryStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[string]*list.Element)
+		s.lru = list.New()
 	}
 
-	c.Cache[key] = &cacheEntry{
-		bytes.NewReader(resBuffer.Bytes()),
-		bodyBuffer.Bytes(),
-		nil,
+	if element, ok := s.entries[key]; ok {
+		s.removeElement(element)
 	}
 
+	entry := &memoryEntry{Key: key, Value: value, Stored: time.Now()}
+	s.entries[key] = s.lru.PushFront(entry)
+	s.bytes += int64(len(value))
+	s.evict()
+
 	return nil
 }
 
-func (c *MemoryCache) Reset(req *http.Request) error {
-	if key, entry, ok := c.getEntry(req); ok {
-		entry.Response = nil
-		c.Cache[key] = entry
-	}
+func (s *MemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
+	if element, ok := s.entries[key]; ok {
+		s.removeElement(element)
+	}
 	return nil
 }
 
-func (c *MemoryCache) Clear(req *http.Request) error {
-	delete(c.Cache, RequestKey(req))
-	return nil
-}
+func (s *MemoryStore) Range(fn func(key string) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-func (c *MemoryCache) UpdateCache(req *http.Request, res *http.Response) error {
-	key, entry, ok := c.getEntry(req)
-	if !ok {
-		return NoResponseError
+	for key, element := range s.entries {
+		if s.expired(element.Value.(*memoryEntry)) {
+			continue
+		}
+		if !fn(key) {
+			break
+		}
 	}
 
-	cached, err := entry.Decode(c)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	cached.Expires = expiration(res)
+// Len returns the number of stored values.
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
 
-	buf := &bytes.Buffer{}
-	EncodeResponse(cached.CachedResponse, buf)
-	entry.Response = bytes.NewReader(buf.Bytes())
-	c.Cache[key] = entry
-	return nil
+// Bytes returns the total size of the stored values.
+func (s *MemoryStore) Bytes() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bytes
 }
 
-func (c *MemoryCache) SetRels(req *http.Request, rels hypermedia.Relations) error {
-	key := RequestKey(req)
-	entry, ok := c.Cache[key]
-	if !ok {
-		return errors.New("No entry for " + key)
-	}
+// Evictions returns the number of values removed because of the MaxEntries,
+// MaxBytes, or TTL limits.
+func (s *MemoryStore) Evictions() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.evictions
+}
 
-	entry.Relations = rels
-	return nil
+// memoryEntry is a stored value.  Stored is when it was last put.
+type memoryEntry struct {
+	Key    string
+	Value  []byte
+	Stored time.Time
 }
 
-func (c *MemoryCache) Rels(req *http.Request) (hypermedia.Relations, bool) {
-	key := RequestKey(req)
-	if entry, ok := c.Cache[key]; ok && entry.Relations != nil {
-		return entry.Relations, true
+// getEntry returns the entry for the key, and marks it as recently used.  An
+// entry past its TTL is evicted instead.  The mutex must be held.
+func (s *MemoryStore) getEntry(key string) (*memoryEntry, bool) {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
 	}
 
-	return nil, false
-}
+	entry := element.Value.(*memoryEntry)
+	if s.expired(entry) {
+		s.removeElement(element)
+		s.evictions += 1
+		return nil, false
+	}
 
-type cacheEntry struct {
-	Response  *bytes.Reader
-	Body      []byte
-	Relations hypermedia.Relations
+	s.lru.MoveToFront(element)
+	return entry, true
 }
 
-func (e *cacheEntry) Decode(cacher sawyer.Cacher) (*CachedResponseDecoder, error) {
-	cachedResponse, err := Decode(e.Response)
-	e.Response.Seek(0, 0)
-
-	if err == nil {
-		cachedResponse.Cacher = cacher
-		cachedResponse.SetBodyFunc = func(res *sawyer.Response) {
-			res.Body = ioutil.NopCloser(bytes.NewBuffer(e.Body))
-			res.BodyClosed = false
+// evict removes the least recently used entries until the store is within its
+// limits.  The mutex must be held.
+func (s *MemoryStore) evict() {
+	for element := s.lru.Back(); element != nil; element = s.lru.Back() {
+		if !s.overLimit() && !s.expired(element.Value.(*memoryEntry)) {
+			return
 		}
+
+		s.removeElement(element)
+		s.evictions += 1
 	}
+}
 
-	return cachedResponse, err
+func (s *MemoryStore) overLimit() bool {
+	return (s.MaxEntries > 0 && s.lru.Len() > s.MaxEntries) ||
+		(s.MaxBytes > 0 && s.bytes > s.MaxBytes)
 }
 
-func (c *MemoryCache) getEntry(req *http.Request) (string, *cacheEntry, bool) {
-	key := RequestKey(req)
-	entry, ok := c.Cache[key]
-	if ok && entry.Response == nil {
-		ok = false
-	}
-	return key, entry, ok
+func (s *MemoryStore) expired(entry *memoryEntry) bool {
+	return s.TTL > 0 && time.Since(entry.Stored) > s.TTL
+}
+
+func (s *MemoryStore) removeElement(element *list.Element) {
+	entry := s.lru.Remove(element).(*memoryEntry)
+	delete(s.entries, entry.Key)
+	s.bytes -= int64(len(entry.Value))
 }
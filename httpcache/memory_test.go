@@ -1,9 +1,167 @@
 package httpcache
 
 import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestMemory(t *testing.T) {
 	CacheResponsesTestFor(NewMemoryCache(), t)
 }
+
+func TestSharedMemory(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.Shared = true
+	SharedCacheTestFor(cache, t)
+}
+
+func TestMemoryStore(t *testing.T) {
+	StoreTestFor(NewMemoryStore(), t)
+}
+
+func TestMemoryMaxEntries(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxEntries = 2
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	getPath(cli, "/b", t)
+
+	// touch /a, so /b is the least recently used
+	_, err := cache.Get(a.Request)
+	assert.Equal(t, nil, err)
+
+	getPath(cli, "/c", t)
+	assert.Equal(t, 2, store.Len())
+	assert.Equal(t, uint64(1), store.Evictions())
+
+	assertMemoryCached(cache, cli, "/a", true, t)
+	assertMemoryCached(cache, cli, "/b", false, t)
+	assertMemoryCached(cache, cli, "/c", true, t)
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	store := NewMemoryStore()
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	// the entries for /a and /b are about the same size, so only one fits
+	getPath(cli, "/a", t)
+	size := store.Bytes()
+	assert.Equal(t, true, size > 0)
+	store.MaxBytes = size + size/2
+
+	getPath(cli, "/b", t)
+	assert.Equal(t, 1, store.Len())
+	assert.Equal(t, true, store.Bytes() <= store.MaxBytes)
+	assert.Equal(t, uint64(1), store.Evictions())
+
+	assertMemoryCached(cache, cli, "/a", false, t)
+	assertMemoryCached(cache, cli, "/b", true, t)
+}
+
+func TestMemoryTTL(t *testing.T) {
+	store := NewMemoryStore()
+	store.TTL = 20 * time.Millisecond
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	getPath(cli, "/a", t)
+	assertMemoryCached(cache, cli, "/a", true, t)
+
+	time.Sleep(30 * time.Millisecond)
+	assertMemoryCached(cache, cli, "/a", false, t)
+	assert.Equal(t, 0, store.Len())
+	assert.Equal(t, uint64(1), store.Evictions())
+}
+
+func TestMemoryCounters(t *testing.T) {
+	cache := NewMemoryCache()
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	getPath(cli, "/a", t)
+	getPath(cli, "/a", t)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), cache.Hits())
+	assert.Equal(t, uint64(1), cache.Misses())
+	assert.Equal(t, stats.Hits, cache.Hits())
+	assert.Equal(t, stats.Misses, cache.Misses())
+	assert.Equal(t, uint64(0), cache.Evictions())
+}
+
+func TestMemoryCacheFields(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.Shared = true
+	cache.MaxEntries = 1
+	assert.Equal(t, true, cache.Cacher.Shared)
+	assert.Equal(t, 1, cache.MemoryStore.MaxEntries)
+
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	getPath(cli, "/a", t)
+	getPath(cli, "/b", t)
+	assert.Equal(t, 1, cache.Len())
+	assertMemoryCached(cache.Cacher, cli, "/b", true, t)
+}
+
+func TestMemoryConcurrentRequests(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxEntries = 5
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				path := "/" + strconv.Itoa((i+j)%8)
+				req, err := cli.NewRequest(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				value := &HttpCacheTestValue{}
+				if err := req.Get().Decode(value); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if value.Name != path {
+					t.Errorf("Expected %s, got %s", path, value.Name)
+				}
+
+				if j%3 == 0 {
+					cache.Reset(req.Request)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, true, store.Len() <= 5)
+}
+
+func assertMemoryCached(cache *Cacher, cli *sawyer.Client, path string, cached bool, t *testing.T) {
+	req, err := cli.NewRequest(path)
+	assert.Equal(t, nil, err)
+	req.Method = sawyer.GetMethod
+
+	_, err = cache.Get(req.Request)
+	assert.Equalf(t, !cached, errors.Is(err, NoResponseError), "cached %s", path)
+}
@@ -0,0 +1,117 @@
+package httpcache
+
+import (
+	"time"
+)
+
+// Stats are the counters of a Cacher.  Evictions and Bytes come from the Store,
+// if it implements StoreStats.
+type Stats struct {
+	Hits          uint64 // Get calls that found a cached response
+	Misses        uint64 // Get calls that did not
+	Revalidations uint64 // conditional requests sent for stale hits
+	NotModified   uint64 // revalidations answered with 304 Not Modified
+	Stores        uint64 // responses stored by Set
+	Evictions     uint64 // entries removed because of the Store's limits
+	Bytes         int64  // total size of the stored entries
+}
+
+// StoreStats is implemented by Stores that can report their size and
+// evictions.  MemoryStore and FileStore implement it.
+type StoreStats interface {
+	Evictions() uint64
+	Bytes() int64
+}
+
+// EntryInfo describes a cache entry.  Status and Expires are from the most
+// recently stored variant, and are zero if the entry only has relations.  Size
+// is the stored size of the entry.
+type EntryInfo struct {
+	Key      string
+	URL      string
+	Status   int
+	Expires  time.Time
+	Size     int64
+	Variants int
+	Rels     bool
+}
+
+// Stats returns the Cacher's counters.
+func (c *Cacher) Stats() Stats {
+	c.statsMutex.Lock()
+	stats := c.stats
+	c.statsMutex.Unlock()
+
+	if store, ok := c.Store.(StoreStats); ok {
+		stats.Evictions = store.Evictions()
+		stats.Bytes = store.Bytes()
+	}
+
+	return stats
+}
+
+// Entries calls fn with the info of each cache entry, until fn returns false.
+// Entries that can't be decoded are skipped.
+func (c *Cacher) Entries(fn func(info *EntryInfo) bool) error {
+	keys, err := c.keys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		value, ok, err := c.Store.Get(key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		entry, err := decodeEntry(value)
+		if err != nil {
+			continue
+		}
+
+		if !fn(entry.info(key, int64(len(value)))) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// info returns the EntryInfo for the entry.  The Store key is used if the
+// entry does not have its cache key.
+func (e *storedEntry) info(key string, size int64) *EntryInfo {
+	info := &EntryInfo{
+		Key:      e.Key,
+		URL:      e.URL,
+		Size:     size,
+		Variants: len(e.Variants),
+		Rels:     e.Relations != nil,
+	}
+
+	if len(info.Key) == 0 {
+		info.Key = key
+	}
+
+	var latest *CachedResponse
+	for _, variant := range e.Variants {
+		if latest == nil || variant.Response.ResponseTime.After(latest.ResponseTime) {
+			latest = variant.Response
+		}
+	}
+
+	if latest != nil {
+		info.Status = latest.StatusCode
+		info.Expires = latest.Expires
+	}
+
+	return info
+}
+
+func (c *Cacher) record(fn func(stats *Stats)) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	fn(&c.stats)
+}
@@ -0,0 +1,93 @@
+package httpcache
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxEntries = 2
+	cache := NewCacher(store)
+	srv, cli := server(cache, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/etag" {
+			pathHandler(w, r)
+			return
+		}
+
+		w.Header().Set("ETag", "abc")
+		w.Header().Set("Cache-Control", "max-age=0")
+		if r.Header.Get("If-None-Match") == "abc" {
+			w.WriteHeader(304)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"/etag"}`))
+	})
+	defer srv.Close()
+
+	getPath(cli, "/a", t)
+	getPath(cli, "/a", t)
+	getPath(cli, "/etag", t)
+	etag := getPath(cli, "/etag", t)
+	getPath(cli, "/b", t)
+
+	// a stale hit that isn't revalidated is not counted
+	_, err := cache.Get(etag.Request)
+	assert.Equal(t, nil, err)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(3), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Revalidations)
+	assert.Equal(t, uint64(1), stats.NotModified)
+	assert.Equal(t, uint64(3), stats.Stores)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, store.Bytes(), stats.Bytes)
+}
+
+func TestEntries(t *testing.T) {
+	cache := NewMemoryCache()
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	a := getPath(cli, "/a", t)
+	getPath(cli, "/b", t)
+
+	var infos []*EntryInfo
+	err := cache.Entries(func(info *EntryInfo) bool {
+		infos = append(infos, info)
+		return true
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(infos))
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].URL < infos[j].URL })
+	info := infos[0]
+	assert.Equal(t, RequestKey(a.Request), info.Key)
+	assert.Equal(t, a.URL.String(), info.URL)
+	assert.Equal(t, 200, info.Status)
+	assert.Equal(t, 1, info.Variants)
+	assert.Equal(t, true, info.Rels)
+	assert.Equal(t, true, info.Size > 0)
+	assert.Equal(t, true, info.Expires.After(time.Now().Add(50*time.Second)))
+
+	// only the relations are left after a reset
+	assert.Equal(t, nil, cache.Reset(a.Request))
+	calls := 0
+	cache.Entries(func(info *EntryInfo) bool {
+		calls += 1
+		if info.URL == a.URL.String() {
+			assert.Equal(t, 0, info.Status)
+			assert.Equal(t, 0, info.Variants)
+			assert.Equal(t, true, info.Rels)
+		}
+		return false
+	})
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,33 @@
+package httpcache
+
+// A Store is a key-value store of byte blobs, used by a Cacher to hold cache
+// entries.  MemoryStore and FileStore are Store implementations.  A Store must
+// be safe for concurrent use.  Callers must not modify the values passed to Put
+// or returned by Get.
+type Store interface {
+	// Get returns the value for the key, and false if it is not stored.
+	Get(key string) ([]byte, bool, error)
+
+	// Put stores the value for the key, replacing any existing value.
+	Put(key string, value []byte) error
+
+	// Delete removes the key.  Deleting a missing key is not an error.
+	Delete(key string) error
+
+	// Range calls fn for each stored key, until fn returns false.  The Store
+	// may not be modified by fn.
+	Range(fn func(key string) bool) error
+}
+
+// An UpdatingStore is a Store that can change a value atomically, even across
+// processes that share the Store.  A Cacher uses Update for every change to an
+// existing entry.  FileStore implements it.
+type UpdatingStore interface {
+	Store
+
+	// Update calls fn with the value for the key, and false if it is not
+	// stored, and stores the value that fn returns.  A nil value deletes the
+	// key.  If fn returns an error, the Store is left as it is, and Update
+	// returns the error.  fn may not use the Store.
+	Update(key string, fn func(value []byte, ok bool) ([]byte, error)) error
+}
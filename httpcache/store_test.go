@@ -0,0 +1,271 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNetworkStore(t *testing.T) {
+	srv, store := networkStore()
+	defer srv.Close()
+	StoreTestFor(store, t)
+}
+
+func TestNetworkCache(t *testing.T) {
+	srv, store := networkStore()
+	defer srv.Close()
+	CacheResponsesTestFor(NewCacher(store), t)
+}
+
+func StoreTestFor(store Store, t *testing.T) {
+	_, ok, err := store.Get("a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, ok)
+
+	assert.Equal(t, nil, store.Put("a", []byte("1")))
+	assert.Equal(t, nil, store.Put("b/c", []byte("2")))
+	assert.Equal(t, nil, store.Put("a", []byte("3")))
+
+	value, ok, err := store.Get("a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "3", string(value))
+
+	value, ok, err = store.Get("b/c")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "2", string(value))
+
+	assert.Equal(t, []string{"a", "b/c"}, storeKeys(store, t))
+
+	calls := 0
+	assert.Equal(t, nil, store.Range(func(key string) bool {
+		calls += 1
+		return false
+	}))
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, nil, store.Delete("a"))
+	assert.Equal(t, nil, store.Delete("missing"))
+
+	_, ok, err = store.Get("a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, []string{"b/c"}, storeKeys(store, t))
+
+	assert.Equal(t, nil, store.Delete("b/c"))
+	assert.Equal(t, 0, len(storeKeys(store, t)))
+}
+
+func UpdatingStoreTestFor(store UpdatingStore, t *testing.T) {
+	assert.Equal(t, nil, store.Update("a", func(value []byte, ok bool) ([]byte, error) {
+		assert.Equal(t, false, ok)
+		return []byte("1"), nil
+	}))
+
+	assert.Equal(t, nil, store.Update("a", func(value []byte, ok bool) ([]byte, error) {
+		assert.Equal(t, true, ok)
+		return append(value, '2'), nil
+	}))
+
+	value, _, err := store.Get("a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "12", string(value))
+
+	failed := errors.New("failed")
+	assert.Equal(t, failed, store.Update("a", func(value []byte, ok bool) ([]byte, error) {
+		return []byte("3"), failed
+	}))
+
+	value, _, err = store.Get("a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "12", string(value))
+
+	assert.Equal(t, nil, store.Update("a", func(value []byte, ok bool) ([]byte, error) {
+		return nil, nil
+	}))
+	assert.Equal(t, 0, len(storeKeys(store, t)))
+}
+
+func TestCacherUpdatesEntries(t *testing.T) {
+	store := &countingStore{MemoryStore: NewMemoryStore()}
+	cache := NewCacher(store)
+	srv, cli := server(cache, pathHandler)
+	defer srv.Close()
+
+	// the response and its relations are stored
+	req := getPath(cli, "/a", t)
+	assert.Equal(t, 2, store.Updates)
+
+	// decoding the cached response doesn't rewrite the unchanged relations
+	getPath(cli, "/a", t)
+	assert.Equal(t, 2, store.Updates)
+
+	assert.Equal(t, nil, cache.Reset(req.Request))
+	assert.Equal(t, 3, store.Updates)
+	assert.Equal(t, nil, cache.InvalidatePrefix(srv.URL))
+	assert.Equal(t, 4, store.Updates)
+
+	// every change went through Update
+	assert.Equal(t, 0, store.Puts)
+}
+
+// countingStore is an UpdatingStore that counts its writes.
+type countingStore struct {
+	*MemoryStore
+	Puts    int
+	Updates int
+}
+
+func (s *countingStore) Put(key string, value []byte) error {
+	s.Puts += 1
+	return s.MemoryStore.Put(key, value)
+}
+
+func (s *countingStore) Update(key string, fn func(value []byte, ok bool) ([]byte, error)) error {
+	s.Updates += 1
+	value, ok, err := s.MemoryStore.Get(key)
+	if err != nil {
+		return err
+	}
+
+	value, err = fn(value, ok)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return s.MemoryStore.Delete(key)
+	}
+	return s.MemoryStore.Put(key, value)
+}
+
+func storeKeys(store Store, t *testing.T) []string {
+	var keys []string
+	err := store.Range(func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, nil, err)
+
+	sort.Strings(keys)
+	return keys
+}
+
+// networkStore starts an in-process key-value server, and returns a Store that
+// talks to it over HTTP.
+func networkStore() (*httptest.Server, *httpStore) {
+	var mutex sync.Mutex
+	values := make(map[string][]byte)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		switch {
+		case r.Method == "GET" && key == "":
+			for key := range values {
+				fmt.Fprintln(w, url.PathEscape(key))
+			}
+		case r.Method == "GET":
+			if value, ok := values[key]; ok {
+				w.Write(value)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == "PUT":
+			values[key], _ = ioutil.ReadAll(r.Body)
+		case r.Method == "DELETE":
+			delete(values, key)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	return srv, &httpStore{URL: srv.URL}
+}
+
+// httpStore is a Store backed by the networkStore server.
+type httpStore struct {
+	URL string
+}
+
+func (s *httpStore) Get(key string) ([]byte, bool, error) {
+	res, err := http.Get(s.keyURL(key))
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	value, err := ioutil.ReadAll(res.Body)
+	return value, err == nil, err
+}
+
+func (s *httpStore) Put(key string, value []byte) error {
+	return s.do("PUT", key, value)
+}
+
+func (s *httpStore) Delete(key string) error {
+	return s.do("DELETE", key, nil)
+}
+
+func (s *httpStore) Range(fn func(key string) bool) error {
+	res, err := http.Get(s.URL + "/")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Fields(string(body)) {
+		key, err := url.PathUnescape(line)
+		if err != nil {
+			return err
+		}
+		if !fn(key) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *httpStore) do(method, key string, value []byte) error {
+	req, err := http.NewRequest(method, s.keyURL(key), bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New(res.Status)
+	}
+	return nil
+}
+
+func (s *httpStore) keyURL(key string) string {
+	return s.URL + "/" + url.PathEscape(key)
+}
@@ -0,0 +1,171 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// A BodyTransform encodes the cached response bodies before they are stored,
+// and decodes them when they are served.  See Cacher.Transforms.
+type BodyTransform interface {
+	// Name identifies the transform in the stored entries.
+	Name() string
+
+	// Encode returns the body as it is stored.  The key identifies the entry
+	// and variant the body is stored for.
+	Encode(body, key []byte) ([]byte, error)
+
+	// Decode returns the original body from the stored data, given the key it
+	// was encoded with.
+	Decode(data, key []byte) ([]byte, error)
+}
+
+// GzipTransform compresses the cached bodies with gzip.  Level is a
+// compress/gzip compression level, and defaults to gzip.DefaultCompression.
+type GzipTransform struct {
+	Level int
+}
+
+func (t *GzipTransform) Name() string {
+	return "gzip"
+}
+
+func (t *GzipTransform) Encode(body, key []byte) ([]byte, error) {
+	level := t.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	buf := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (t *GzipTransform) Decode(data, key []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+// AESGCMTransform encrypts the cached bodies with AES-GCM.  Each body is sealed
+// with a random nonce, which is stored in front of the ciphertext.  The key is
+// authenticated as additional data, so a body copied to another entry or
+// variant fails to decode.
+type AESGCMTransform struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMTransform returns an AESGCMTransform for the given 16, 24 or 32 byte
+// key, to use AES-128, AES-192 or AES-256.
+func NewAESGCMTransform(key []byte) (*AESGCMTransform, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESGCMTransform{aead}, nil
+}
+
+func (t *AESGCMTransform) Name() string {
+	return "aes-gcm"
+}
+
+func (t *AESGCMTransform) Encode(body, key []byte) ([]byte, error) {
+	nonce := make([]byte, t.aead.NonceSize(), t.aead.NonceSize()+len(body)+t.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return t.aead.Seal(nonce, nonce, body, key), nil
+}
+
+func (t *AESGCMTransform) Decode(data, key []byte) ([]byte, error) {
+	size := t.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("Encrypted body is too short")
+	}
+
+	return t.aead.Open(nil, data[:size], data[size:], key)
+}
+
+// encodeBody applies the Cacher's transforms to the body, and returns their
+// names.  The key is from transformKey.
+func (c *Cacher) encodeBody(body, key []byte) ([]byte, []string, error) {
+	var names []string
+	for _, transform := range c.Transforms {
+		var err error
+		if body, err = transform.Encode(body, key); err != nil {
+			return nil, nil, err
+		}
+		names = append(names, transform.Name())
+	}
+	return body, names, nil
+}
+
+// decodeBody undoes the named transforms, in reverse order.
+func (c *Cacher) decodeBody(data []byte, names []string, key []byte) ([]byte, error) {
+	for i := len(names) - 1; i >= 0; i-- {
+		transform := c.transform(names[i])
+		if transform == nil {
+			return nil, errors.New("Unknown body transform " + names[i])
+		}
+
+		var err error
+		if data, err = transform.Decode(data, key); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// canDecode checks if the Cacher has all of the named transforms.
+func (c *Cacher) canDecode(names []string) bool {
+	for _, name := range names {
+		if c.transform(name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// transformKey returns the key that the body of the entry's variant is
+// transformed with.
+func transformKey(key, variantKey string) []byte {
+	return []byte(key + "\x00" + variantKey)
+}
+
+func (c *Cacher) transform(name string) BodyTransform {
+	for _, transform := range c.Transforms {
+		if transform.Name() == name {
+			return transform
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package httpcache
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTransformedMemory(t *testing.T) {
+	aes, err := NewAESGCMTransform(bytes.Repeat([]byte("k"), 32))
+	assert.Equal(t, nil, err)
+
+	cache := NewMemoryCache()
+	cache.Transforms = []BodyTransform{&GzipTransform{}, aes}
+	CacheResponsesTestFor(cache, t)
+}
+
+func TestGzipTransform(t *testing.T) {
+	body := []byte(strings.Repeat(`{"Name":"sawyer"}`, 100))
+	transform := &GzipTransform{}
+
+	data, err := transform.Encode(body, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, len(data) < len(body))
+
+	decoded, err := transform.Decode(data, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, string(body), string(decoded))
+}
+
+func TestAESGCMTransform(t *testing.T) {
+	transform, err := NewAESGCMTransform(bytes.Repeat([]byte("k"), 16))
+	assert.Equal(t, nil, err)
+
+	key := []byte("application/json:https://api.github.com/user")
+	data, err := transform.Encode([]byte("secret"), key)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, bytes.Contains(data, []byte("secret")))
+
+	decoded, err := transform.Decode(data, key)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "secret", string(decoded))
+
+	// the body is bound to its key
+	_, err = transform.Decode(data, []byte("application/json:https://api.github.com/users/bob"))
+	assert.NotEqual(t, nil, err)
+
+	data[len(data)-1] ^= 0xff
+	_, err = transform.Decode(data, key)
+	assert.NotEqual(t, nil, err)
+
+	other, err := NewAESGCMTransform(bytes.Repeat([]byte("o"), 16))
+	assert.Equal(t, nil, err)
+	data, _ = transform.Encode([]byte("secret"), key)
+	_, err = other.Decode(data, key)
+	assert.NotEqual(t, nil, err)
+
+	_, err = NewAESGCMTransform([]byte("short"))
+	assert.NotEqual(t, nil, err)
+}
+
+func TestTransformedBodiesAtRest(t *testing.T) {
+	aes, err := NewAESGCMTransform(bytes.Repeat([]byte("k"), 32))
+	assert.Equal(t, nil, err)
+
+	store := NewMemoryStore()
+	cache := NewCacher(store)
+	cache.Transforms = []BodyTransform{&GzipTransform{}, aes}
+	srv, cli := server(cache, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"` + r.URL.Path + `","Secret":"hunter2"}`))
+	})
+	defer srv.Close()
+
+	req := getPath(cli, "/a", t)
+	value, ok, err := store.Get(cache.key(req.Request))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, false, bytes.Contains(value, []byte("hunter2")))
+
+	cached, err := cache.Get(req.Request)
+	assert.Equal(t, nil, err)
+	res := cached.Decode(req)
+	assert.Equal(t, `{"Name":"/a","Secret":"hunter2"}`, readBody(res.Response, t))
+
+	// a cache without the transforms can't read the entry
+	plain := NewCacher(store)
+	_, err = plain.Get(req.Request)
+	assert.Equal(t, NoResponseError, err)
+
+	// a body copied to another entry fails to decode, and the entry is deleted
+	b := getPath(cli, "/b", t)
+	assert.Equal(t, nil, store.Put(cache.key(b.Request), value))
+	_, err = cache.Get(b.Request)
+	assert.Equal(t, NoResponseError, err)
+	_, ok, _ = store.Get(cache.key(b.Request))
+	assert.Equal(t, false, ok)
+
+	// so does a body with the wrong encryption key
+	wrong, err := NewAESGCMTransform(bytes.Repeat([]byte("w"), 32))
+	assert.Equal(t, nil, err)
+	plain.Transforms = []BodyTransform{&GzipTransform{}, wrong}
+	_, err = plain.Get(req.Request)
+	assert.Equal(t, NoResponseError, err)
+	_, ok, _ = store.Get(cache.key(req.Request))
+	assert.Equal(t, false, ok)
+}
@@ -0,0 +1,269 @@
+package httpcache
+
+import (
+	"bytes"
+	"github.com/lostisland/go-sawyer"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// XFromCache is the header set on responses served by a Transport from its
+// cache.
+const XFromCache = "X-From-Cache"
+
+// Transport is an http.RoundTripper that caches GET responses in a
+// sawyer.Cacher, so any net/http Client can share a cache with sawyer Clients.
+// Fresh responses are served from the cache, and stale responses are
+// revalidated with a conditional request.  The headers of a 304 Not Modified
+// response are merged into the cached response.
+type Transport struct {
+	Cacher sawyer.Cacher
+
+	// Accept is the Accept header of the cached GET requests, which is part of
+	// their cache key, like the default Accept header of a sawyer Client that
+	// shares the Cacher.  It's used to find the cached response that an unsafe
+	// request invalidates.  If empty, the unsafe request's own Accept header is
+	// used.
+	Accept string
+
+	// Transport sends the requests.  If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+// NewTransport returns a Transport that caches responses in the given Cacher.
+func NewTransport(cacher sawyer.Cacher) *Transport {
+	return &Transport{Cacher: cacher}
+}
+
+// Client returns a net/http Client that uses the Transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip implements the http.RoundTripper interface.  Requests that are not
+// GET, or that already have conditional or Range headers, are passed through.
+// A successful POST, PUT, PATCH or DELETE invalidates the cached response for
+// its URL.  A cached response that can't be decoded is treated as a miss.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != sawyer.GetMethod || bypassCache(req) {
+		res, err := t.transport().RoundTrip(req)
+		if err == nil && invalidates(req.Method) && res.StatusCode < 400 {
+			t.invalidate(req)
+		}
+		return res, err
+	}
+
+	cached, err := t.Cacher.Get(req)
+	if err != nil {
+		return t.fetch(req)
+	}
+
+	if cached.IsFresh() {
+		if res, err := fromCache(req, cached); err == nil {
+			return res, nil
+		}
+		return t.fetch(req)
+	}
+
+	condReq := req.Clone(req.Context())
+	cached.SetupRequest(condReq)
+
+	res, err := t.transport().RoundTrip(condReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		res.Request = req
+		return t.store(req, res)
+	}
+
+	res.Body.Close()
+	if res, err := t.revalidated(req, cached, res); err == nil {
+		return res, nil
+	}
+	return t.fetch(req)
+}
+
+// fetch sends the request without a cached response, and caches the response.
+func (t *Transport) fetch(req *http.Request) (*http.Response, error) {
+	res, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.store(req, res)
+}
+
+// invalidate removes the cached response for the URL of an unsafe request, as
+// described in RFC 9111, section 4.4.  Like a sawyer Request, a DELETE clears
+// the relations too.
+func (t *Transport) invalidate(req *http.Request) {
+	target := t.cacheTarget(req)
+	if req.Method == sawyer.DeleteMethod {
+		t.Cacher.Clear(target)
+	} else {
+		t.Cacher.Reset(target)
+	}
+}
+
+// cacheTarget returns a GET request for the URL of the unsafe request, with the
+// same cache key as the cached GET response.  The headers are copied, so the
+// cache is partitioned by the same credentials and cookies, except for the
+// body headers, and the Accept header if the Transport has one.
+func (t *Transport) cacheTarget(req *http.Request) *http.Request {
+	target := req.Clone(req.Context())
+	target.Method = sawyer.GetMethod
+	target.Body = nil
+	target.GetBody = nil
+	target.ContentLength = 0
+
+	for key := range target.Header {
+		if strings.HasPrefix(key, contentHeaderPrefix) {
+			target.Header.Del(key)
+		}
+	}
+
+	if len(t.Accept) > 0 {
+		target.Header.Set(keyHeader, t.Accept)
+	}
+	return target
+}
+
+// store caches a successful response.  The body is read into memory, so that
+// the caller gets the full body even if the Cacher fails.  Responses that the
+// Cacher can't store are returned unread.
+func (t *Transport) store(req *http.Request, res *http.Response) (*http.Response, error) {
+	if res.StatusCode < 200 || res.StatusCode > 299 || !t.storable(req, res) {
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	t.set(req, res, body)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// revalidated merges the headers of the 304 response into the cached response,
+// and stores it again.
+func (t *Transport) revalidated(req *http.Request, cached sawyer.CachedResponse, notModified *http.Response) (*http.Response, error) {
+	res := cached.Decode(&sawyer.Request{Request: req})
+	if res.ResponseError != nil {
+		return nil, res.ResponseError
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Header = mergeHeader(res.Header, notModified.Header)
+	t.set(req, res.Response, body)
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.Header.Set(XFromCache, "1")
+	return res.Response, nil
+}
+
+// set stores a copy of the response with the given body in the Cacher.
+func (t *Transport) set(req *http.Request, res *http.Response, body []byte) error {
+	stored := *res
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	mtype, _ := mediatype.Parse(res.Header.Get(ctypeHeader))
+	return t.Cacher.Set(req, &sawyer.Response{MediaType: mtype, Response: &stored})
+}
+
+// storable returns true if the Cacher may store the response.  A Cacher from
+// this package is shared if its Shared field is set, and other Cachers are
+// treated as private caches.
+func (t *Transport) storable(req *http.Request, res *http.Response) bool {
+	shared := false
+	if c, ok := t.Cacher.(sharedCacher); ok {
+		shared = c.shared()
+	}
+	return Storable(req, res, shared)
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// fromCache returns the cached response, with the XFromCache header set.
+func fromCache(req *http.Request, cached sawyer.CachedResponse) (*http.Response, error) {
+	res := cached.Decode(&sawyer.Request{Request: req})
+	if res.ResponseError != nil {
+		return nil, res.ResponseError
+	}
+
+	if res.Body == nil {
+		res.Body = http.NoBody
+	}
+
+	res.Header.Set(XFromCache, "1")
+	return res.Response, nil
+}
+
+// mergeHeader returns a copy of the stored header, updated with the fields from
+// a 304 response, as described in RFC 9111, section 3.2.
+func mergeHeader(stored, updated http.Header) http.Header {
+	header := stored.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for key, values := range updated {
+		switch key {
+		case contentLengthHeader, transferEncodingHeader, XFromCache:
+			continue
+		}
+		header[key] = values
+	}
+	return header
+}
+
+// invalidates returns true for the unsafe methods that invalidate the cached
+// response for their URL.
+func invalidates(method string) bool {
+	switch method {
+	case sawyer.PostMethod, sawyer.PutMethod, sawyer.PatchMethod, sawyer.DeleteMethod:
+		return true
+	}
+	return false
+}
+
+// bypassCache returns true if the request makes its own conditional or partial
+// request, which the cache can't answer.
+func bypassCache(req *http.Request) bool {
+	for _, key := range []string{ifNoneMatchHeader, ifModSinceHeader, ifMatchHeader, ifUnmodSinceHeader, rangeHeader} {
+		if len(req.Header.Get(key)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedCacher is implemented by the Cachers in this package, including the
+// MemoryCache and FileCache that embed a *Cacher.
+type sharedCacher interface {
+	shared() bool
+}
+
+const (
+	contentHeaderPrefix    = "Content-"
+	ctypeHeader            = "Content-Type"
+	contentLengthHeader    = "Content-Length"
+	transferEncodingHeader = "Transfer-Encoding"
+	ifMatchHeader          = "If-Match"
+	ifUnmodSinceHeader     = "If-Unmodified-Since"
+	rangeHeader            = "Range"
+)
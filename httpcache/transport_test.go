@@ -0,0 +1,288 @@
+package httpcache
+
+import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TransportTestFor(cacher sawyer.Cacher, t *testing.T) {
+	TransportCacheTestFor(cacher, t)
+	TransportRevalidateTestFor(cacher, t)
+	TransportPassThroughTestFor(cacher, t)
+	TransportSharedTestFor(cacher, t)
+	TransportInvalidatesTestFor("POST", cacher, t)
+	TransportInvalidatesTestFor("DELETE", cacher, t)
+	TransportInvalidatesAcceptTestFor(cacher, t)
+	TransportUnstorableTestFor(cacher, t)
+	TransportDecodeErrorTestFor(cacher, t)
+}
+
+func TransportCacheTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		pathHandler(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewTransport(cacher).Client()
+
+	res, err := client.Get(srv.URL + "/a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", res.Header.Get(XFromCache))
+	assert.Equal(t, `{"Name":"/a"}`, readBody(res, t))
+
+	res, err = client.Get(srv.URL + "/a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get(XFromCache))
+	assert.Equal(t, `{"Name":"/a"}`, readBody(res, t))
+	assert.Equal(t, 1, requests)
+}
+
+func TransportRevalidateTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("X-Revision", "2")
+			w.WriteHeader(304)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("X-Revision", "1")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"Name":"v1"}`))
+	}))
+	defer srv.Close()
+
+	client := NewTransport(cacher).Client()
+
+	res, err := client.Get(srv.URL)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1", res.Header.Get("X-Revision"))
+	assert.Equal(t, `{"Name":"v1"}`, readBody(res, t))
+
+	// stale, so it is revalidated, and the 304's headers are merged
+	res, err = client.Get(srv.URL)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get(XFromCache))
+	assert.Equal(t, "2", res.Header.Get("X-Revision"))
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Equal(t, `{"Name":"v1"}`, readBody(res, t))
+	assert.Equal(t, 2, requests)
+
+	// the merged Cache-Control header makes it fresh
+	res, err = client.Get(srv.URL)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "2", res.Header.Get("X-Revision"))
+	assert.Equal(t, `{"Name":"v1"}`, readBody(res, t))
+	assert.Equal(t, 2, requests)
+}
+
+func TransportPassThroughTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(304)
+			return
+		}
+		pathHandler(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewTransport(cacher).Client()
+
+	res, err := client.Post(srv.URL+"/post", "application/json", nil)
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	res, err = client.Post(srv.URL+"/post", "application/json", nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", res.Header.Get(XFromCache))
+	readBody(res, t)
+	assert.Equal(t, 2, requests)
+
+	res, err = client.Get(srv.URL + "/get")
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	// the caller's own conditional request gets the 304
+	req, err := http.NewRequest("GET", srv.URL+"/get", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("If-None-Match", `"v1"`)
+
+	res, err = client.Do(req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 304, res.StatusCode)
+	readBody(res, t)
+	assert.Equal(t, 4, requests)
+}
+
+func TransportSharedTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		pathHandler(w, r)
+	})
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/shared", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept", cli.Header.Get("Accept"))
+
+	res, err := NewTransport(cacher).Client().Do(req)
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	// the sawyer client reads the response cached by the Transport
+	getPath(cli, "/shared", t)
+	assert.Equal(t, 1, requests)
+}
+
+func TransportInvalidatesTestFor(method string, cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		pathHandler(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewTransport(cacher).Client()
+
+	res, err := client.Get(srv.URL + "/item")
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	req, err := http.NewRequest(method, srv.URL+"/item", nil)
+	assert.Equal(t, nil, err)
+	res, err = client.Do(req)
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	res, err = client.Get(srv.URL + "/item")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", res.Header.Get(XFromCache))
+	readBody(res, t)
+	assert.Equal(t, 3, requests)
+}
+
+func TransportInvalidatesAcceptTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv, cli := server(cacher, func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		pathHandler(w, r)
+	})
+	defer srv.Close()
+
+	getPath(cli, "/accept", t)
+
+	// the POST has its own Accept and body headers, but invalidates the
+	// response cached by the sawyer client
+	transport := &Transport{Cacher: cacher, Accept: cli.Header.Get("Accept")}
+	req, err := http.NewRequest("POST", srv.URL+"/accept", strings.NewReader(`{}`))
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := transport.Client().Do(req)
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	getPath(cli, "/accept", t)
+	assert.Equal(t, 3, requests)
+}
+
+func TransportUnstorableTestFor(cacher sawyer.Cacher, t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(200)
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte(`{"Name":"/unstorable"}`))
+	}))
+	defer srv.Close()
+
+	// the response is returned before the server finishes the body
+	done := make(chan *http.Response, 1)
+	go func() {
+		res, err := NewTransport(cacher).Client().Get(srv.URL + "/unstorable")
+		assert.Equal(t, nil, err)
+		done <- res
+	}()
+
+	select {
+	case res := <-done:
+		close(release)
+		assert.Equal(t, "", res.Header.Get(XFromCache))
+		assert.Equal(t, `{"Name":"/unstorable"}`, readBody(res, t))
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("the unstorable response body was read by the Transport")
+	}
+}
+
+func TransportDecodeErrorTestFor(cacher sawyer.Cacher, t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		pathHandler(w, r)
+	}))
+	defer srv.Close()
+
+	res, err := NewTransport(cacher).Client().Get(srv.URL + "/undecodable")
+	assert.Equal(t, nil, err)
+	readBody(res, t)
+
+	client := NewTransport(&undecodableCacher{cacher}).Client()
+	res, err = client.Get(srv.URL + "/undecodable")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "", res.Header.Get(XFromCache))
+	assert.Equal(t, `{"Name":"/undecodable"}`, readBody(res, t))
+	assert.Equal(t, 2, requests)
+}
+
+// undecodableCacher returns cached responses that fail to decode.
+type undecodableCacher struct {
+	sawyer.Cacher
+}
+
+func (c *undecodableCacher) Get(req *http.Request) (sawyer.CachedResponse, error) {
+	cached, err := c.Cacher.Get(req)
+	if err != nil {
+		return nil, err
+	}
+	return &undecodableResponse{cached}, nil
+}
+
+type undecodableResponse struct {
+	sawyer.CachedResponse
+}
+
+func (r *undecodableResponse) Decode(req *sawyer.Request) *sawyer.Response {
+	return sawyer.ResponseError(errors.New("undecodable"))
+}
+
+func readBody(res *http.Response, t *testing.T) string {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Equal(t, nil, err)
+	return string(body)
+}
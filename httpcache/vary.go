@@ -0,0 +1,82 @@
+package httpcache
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MatchesVary returns true if the given request has the same values as the
+// original request for all of the header fields in the response's Vary header.
+func (r *CachedResponse) MatchesVary(req *http.Request) bool {
+	for _, field := range r.VaryFields {
+		if varyValue(req.Header, field) != varyValue(r.VaryHeader, field) {
+			return false
+		}
+	}
+	return true
+}
+
+// setVary stores the request header fields named by the response's Vary header.
+// It returns false if the response varies on "*", which means it can never be
+// reused.
+func (r *CachedResponse) setVary(req *http.Request, resHeader http.Header) bool {
+	fields, ok := varyFields(resHeader)
+	if !ok {
+		return false
+	}
+
+	r.VaryFields = fields
+	r.VaryHeader = make(http.Header)
+	for _, field := range fields {
+		if values := req.Header.Values(field); len(values) > 0 {
+			r.VaryHeader[field] = values
+		}
+	}
+	return true
+}
+
+// variantKey returns a string that identifies the variant of the given request,
+// for the given Vary fields.
+func variantKey(header http.Header, fields []string) string {
+	pieces := make([]string, len(fields))
+	for i, field := range fields {
+		pieces[i] = field + "=" + varyValue(header, field)
+	}
+	return strings.Join(pieces, "\n")
+}
+
+// varyFields returns the sorted, canonical header fields named by the Vary
+// header.  It returns false for "Vary: *".
+func varyFields(header http.Header) ([]string, bool) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, value := range header.Values(varyHeaderName) {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" {
+				return nil, false
+			}
+
+			field = http.CanonicalHeaderKey(field)
+			if len(field) > 0 && !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	sort.Strings(fields)
+	return fields, true
+}
+
+// varyValue normalizes the values of a header field for comparison.
+func varyValue(header http.Header, field string) string {
+	var values []string
+	for _, value := range header.Values(field) {
+		values = append(values, strings.Join(strings.Fields(value), " "))
+	}
+	return strings.Join(values, ",")
+}
+
+const varyHeaderName = "Vary"
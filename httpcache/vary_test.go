@@ -0,0 +1,44 @@
+package httpcache
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"testing"
+)
+
+func TestVaryFields(t *testing.T) {
+	header := http.Header{}
+	header.Add("Vary", "accept-encoding, Accept")
+	header.Add("Vary", "Accept-Encoding")
+
+	fields, ok := varyFields(header)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []string{"Accept", "Accept-Encoding"}, fields)
+
+	header.Set("Vary", "Accept, *")
+	_, ok = varyFields(header)
+	assert.Equal(t, false, ok)
+}
+
+func TestMatchesVary(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resHeader := http.Header{}
+	resHeader.Set("Vary", "Accept-Encoding, Accept-Language")
+
+	cached := &CachedResponse{}
+	assert.Equal(t, true, cached.setVary(req, resHeader))
+	assert.Equal(t, true, cached.MatchesVary(req))
+
+	other, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	assert.Equal(t, nil, err)
+	other.Header.Set("Accept-Encoding", "gzip")
+	assert.Equal(t, true, cached.MatchesVary(other))
+
+	other.Header.Set("Accept-Language", "en")
+	assert.Equal(t, false, cached.MatchesVary(other))
+
+	assert.NotEqual(t, variantKey(req.Header, cached.VaryFields), variantKey(other.Header, cached.VaryFields))
+}
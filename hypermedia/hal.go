@@ -29,9 +29,17 @@ func (r *HALResource) HypermediaRels(rels Relations) {
 // currently only supports single link objects.
 type Links map[string]Link
 
-// Link represents a single link in a HALResource.
+// Link represents a single link in a HALResource, or in a Link header.  Rel,
+// Anchor and Params are only set for links parsed from a Link header.  Params
+// has every target attribute of the link, including the ones with fields.
 type Link struct {
-	Href Hyperlink `json:"href"`
+	Href     Hyperlink         `json:"href"`
+	Title    string            `json:"title,omitempty"`
+	Type     string            `json:"type,omitempty"`
+	Hreflang string            `json:"hreflang,omitempty"`
+	Rel      []string          `json:"-"`
+	Anchor   string            `json:"-"`
+	Params   map[string]string `json:"-"`
 }
 
 // Expand converts a uri template into a url.URL using the given M map.
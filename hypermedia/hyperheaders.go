@@ -6,49 +6,248 @@ import (
 	"strings"
 )
 
-// TODO: need a full link header parser for http://tools.ietf.org/html/rfc5988
+// HyperHeaderRelations gets link relations from the Link headers, as described
+// in RFC 8288.  Every relation type of every link is added to the Relations.
 func HyperHeaderRelations(header http.Header, rels Relations) Relations {
 	if rels == nil {
 		rels = make(Relations)
 	}
 
-	link := header.Get("Link")
-	if len(link) == 0 {
-		return rels
+	for _, link := range ParseLinkHeader(header) {
+		for _, rel := range link.Rel {
+			rels[rel] = link.Href
+		}
 	}
 
-	for _, l := range strings.Split(link, ",") {
-		l = strings.TrimSpace(l)
-		segments := strings.Split(l, ";")
+	return rels
+}
 
-		if len(segments) < 2 {
-			continue
+// ParseLinkHeader parses the links from all Link header fields in the given
+// header.
+func ParseLinkHeader(header http.Header) []Link {
+	var links []Link
+	for _, value := range header.Values(linkHeader) {
+		links = append(links, ParseLinks(value)...)
+	}
+	return links
+}
+
+// ParseLinks parses the links in a single Link header field value, as described
+// in RFC 8288.
+//
+//	<https://api.github.com/user/repos?page=3>; rel="next", </repos>; rel="up index"
+//
+// Relation types are lowercased, since they are compared case-insensitively.
+// Only the first occurrence of each target attribute is used.  Extended
+// attributes like title* are decoded, and take precedence over their plain
+// counterparts.  Malformed links are skipped.
+func ParseLinks(value string) []Link {
+	p := &linkParser{s: value}
+	var links []Link
+
+	for {
+		p.skip(", \t")
+		if p.eof() {
+			return links
 		}
 
-		if !strings.HasPrefix(segments[0], "<") || !strings.HasSuffix(segments[0], ">") {
-			continue
+		if link, ok := p.link(); ok {
+			links = append(links, link)
+		} else {
+			p.skipLink()
+		}
+	}
+}
+
+// linkParser is a scanner for Link header field values.
+type linkParser struct {
+	s   string
+	pos int
+}
+
+// link parses a single link-value: a URI reference in angle brackets, followed
+// by parameters.
+func (p *linkParser) link() (Link, bool) {
+	link := Link{}
+	if !p.consume('<') {
+		return link, false
+	}
+
+	end := strings.IndexByte(p.s[p.pos:], '>')
+	if end < 0 {
+		return link, false
+	}
+
+	u, err := url.Parse(strings.TrimSpace(p.s[p.pos : p.pos+end]))
+	if err != nil {
+		return link, false
+	}
+	link.Href = Hyperlink(u.String())
+	p.pos += end + 1
+
+	extended := make(map[string]bool)
+	for {
+		p.skip(" \t")
+		if p.eof() || p.peek() == ',' {
+			return link, true
+		}
+
+		if !p.consume(';') {
+			return link, false
+		}
+
+		p.skip(" \t")
+		name := strings.ToLower(p.token())
+		if len(name) == 0 {
+			return link, false
+		}
+
+		value := ""
+		p.skip(" \t")
+		if p.consume('=') {
+			p.skip(" \t")
+			if p.peek() == '"' {
+				var ok bool
+				if value, ok = p.quoted(); !ok {
+					return link, false
+				}
+			} else {
+				value = p.token()
+			}
 		}
 
-		url, err := url.Parse(segments[0][1 : len(segments[0])-1])
-		if err != nil {
+		if strings.HasSuffix(name, "*") {
+			name = strings.TrimSuffix(name, "*")
+			if decoded, ok := decodeExtValue(value); ok && !extended[name] {
+				extended[name] = true
+				link.setParam(name, decoded, true)
+			}
 			continue
 		}
 
-		link := Hyperlink(url.String())
+		if !extended[name] {
+			link.setParam(name, value, false)
+		}
+	}
+}
 
-		for _, segment := range segments[1:] {
-			switch strings.TrimSpace(segment) {
-			case `rel="next"`:
-				rels["next"] = link
-			case `rel="prev"`:
-				rels["prev"] = link
-			case `rel="first"`:
-				rels["first"] = link
-			case `rel="last"`:
-				rels["last"] = link
+// setParam sets the target attribute on the link.  Only the first value is
+// kept, unless override is set.
+func (l *Link) setParam(name, value string, override bool) {
+	if l.Params == nil {
+		l.Params = make(map[string]string)
+	}
+
+	if _, ok := l.Params[name]; ok && !override {
+		return
+	}
+	l.Params[name] = value
+
+	switch name {
+	case "rel":
+		l.Rel = strings.Fields(strings.ToLower(value))
+	case "anchor":
+		l.Anchor = value
+	case "title":
+		l.Title = value
+	case "type":
+		l.Type = value
+	case "hreflang":
+		l.Hreflang = value
+	}
+}
+
+// token reads a token or unquoted parameter value.
+func (p *linkParser) token() string {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(tokenDelims, rune(p.peek())) {
+		p.pos += 1
+	}
+	return p.s[start:p.pos]
+}
+
+// quoted reads a quoted-string, unescaping any quoted-pairs.
+func (p *linkParser) quoted() (string, bool) {
+	p.pos += 1
+	var buf []byte
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos += 1
+		switch c {
+		case '"':
+			return string(buf), true
+		case '\\':
+			if p.eof() {
+				return "", false
 			}
+			c = p.s[p.pos]
+			p.pos += 1
 		}
+		buf = append(buf, c)
 	}
+	return "", false
+}
 
-	return rels
+// skipLink skips past the rest of a malformed link, to the next comma that is
+// not inside angle brackets or a quoted-string.
+func (p *linkParser) skipLink() {
+	inQuote, inBrackets := false, false
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos += 1
+		switch {
+		case inQuote && c == '\\':
+			p.pos += 1
+		case c == '"' && !inBrackets:
+			inQuote = !inQuote
+		case c == '<' && !inQuote:
+			inBrackets = true
+		case c == '>' && !inQuote:
+			inBrackets = false
+		case c == ',' && !inQuote && !inBrackets:
+			return
+		}
+	}
 }
+
+func (p *linkParser) skip(chars string) {
+	for !p.eof() && strings.IndexByte(chars, p.s[p.pos]) >= 0 {
+		p.pos += 1
+	}
+}
+
+func (p *linkParser) consume(c byte) bool {
+	if !p.eof() && p.s[p.pos] == c {
+		p.pos += 1
+		return true
+	}
+	return false
+}
+
+func (p *linkParser) peek() byte {
+	return p.s[p.pos]
+}
+
+func (p *linkParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+// decodeExtValue decodes an RFC 8187 ext-value, such as
+// "UTF-8'en'%e2%82%ac%20rates".  Only UTF-8 is supported.
+func decodeExtValue(value string) (string, bool) {
+	pieces := strings.SplitN(value, "'", 3)
+	if len(pieces) != 3 || !strings.EqualFold(pieces[0], "utf-8") {
+		return "", false
+	}
+
+	decoded, err := url.PathUnescape(pieces[2])
+	if err != nil {
+		return "", false
+	}
+	return decoded, true
+}
+
+const (
+	linkHeader  = "Link"
+	tokenDelims = " \t;,=\""
+)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/bmizerany/assert"
+	"net/http"
 	"testing"
 )
 
@@ -109,6 +110,58 @@ func TestDecode(t *testing.T) {
 	assert.Equal(t, "/foo/bar/baz", url.String())
 }
 
+func TestHeaderRelations(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.github.com/user/repos?page=3&per_page=100>; rel="next", <https://api.github.com/user/repos?page=50&per_page=100>; rel="last"`)
+	header.Add("Link", `</search?q=a,b;c>; rel="search alternate"; title="a, b; \"c\""`)
+	header.Add("Link", `</up>; rel=UP`)
+
+	rels := HyperHeaderRelations(header, nil)
+	assert.Equal(t, 5, len(rels))
+	assert.Equal(t, "https://api.github.com/user/repos?page=3&per_page=100", string(rels["next"]))
+	assert.Equal(t, "https://api.github.com/user/repos?page=50&per_page=100", string(rels["last"]))
+	assert.Equal(t, "/search?q=a,b;c", string(rels["search"]))
+	assert.Equal(t, "/search?q=a,b;c", string(rels["alternate"]))
+	assert.Equal(t, "/up", string(rels["up"]))
+}
+
+func TestParseLinks(t *testing.T) {
+	links := ParseLinks(`<http://example.com/TheBook/chapter2>; rel="previous"; title*=UTF-8'de'letztes%20Kapitel; title="last chapter", ` +
+		`</terms>; rel="copyright"; anchor="#foo"; type="text/html"; hreflang=en; hreflang=de; foo=bar`)
+	assert.Equal(t, 2, len(links))
+
+	link := links[0]
+	assert.Equal(t, "http://example.com/TheBook/chapter2", string(link.Href))
+	assert.Equal(t, []string{"previous"}, link.Rel)
+	assert.Equal(t, "letztes Kapitel", link.Title)
+
+	link = links[1]
+	assert.Equal(t, "/terms", string(link.Href))
+	assert.Equal(t, []string{"copyright"}, link.Rel)
+	assert.Equal(t, "#foo", link.Anchor)
+	assert.Equal(t, "text/html", link.Type)
+	assert.Equal(t, "en", link.Hreflang)
+	assert.Equal(t, "bar", link.Params["foo"])
+}
+
+func TestParseLinksFirstRel(t *testing.T) {
+	links := ParseLinks(`</a>; rel="next"; rel="prev"`)
+	assert.Equal(t, 1, len(links))
+	assert.Equal(t, []string{"next"}, links[0].Rel)
+}
+
+func TestParseMalformedLinks(t *testing.T) {
+	links := ParseLinks(`no-brackets; rel="next", </ok>; rel="ok", </unterminated; rel="bad"`)
+	assert.Equal(t, 1, len(links))
+	assert.Equal(t, "/ok", string(links[0].Href))
+
+	links = ParseLinks(`</a> junk; rel="a", </b>; rel="b"`)
+	assert.Equal(t, 1, len(links))
+	assert.Equal(t, "/b", string(links[0].Href))
+
+	assert.Equal(t, 0, len(ParseLinks("")))
+}
+
 func decode(t *testing.T, input string, resource interface{}) {
 	dec := json.NewDecoder(bytes.NewBufferString(input))
 	err := dec.Decode(resource)
@@ -0,0 +1,132 @@
+package sawyer
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// An InvalidationRule returns the URLs to invalidate in the cache after a
+// successful POST, PUT, PATCH or DELETE request.  Relative URLs are resolved
+// against the request URL.  A URL ending in "*" invalidates every cached URL
+// with that prefix, if the Cacher is a PrefixInvalidator.
+type InvalidationRule func(req *Request, res *Response) []string
+
+// Invalidate appends the given rules to the Client's invalidation rules.
+// Requests created with NewRequest() get a copy of the rules at that time.
+//
+// Unsafe requests always invalidate the request URL, and the URLs in the
+// response's Location and Content-Location headers if they have the same
+// origin, as described in RFC 9111, section 4.4.
+func (c *Client) Invalidate(rules ...InvalidationRule) {
+	c.Invalidations = append(c.Invalidations, rules...)
+}
+
+// InvalidateOn returns an InvalidationRule that invalidates the targets after
+// a request with the given method, to a URL path matching the pattern.  The
+// pattern can end in "*" to match a path prefix.
+//
+//	// POST /repos/x/issues invalidates GET /repos/x/issues*
+//	client.Invalidate(sawyer.InvalidateOn("POST", "/repos/x/issues", "/repos/x/issues*"))
+func InvalidateOn(method, pattern string, targets ...string) InvalidationRule {
+	return func(req *Request, res *Response) []string {
+		if req.Method == method && matchPattern(pattern, req.URL.Path) {
+			return targets
+		}
+		return nil
+	}
+}
+
+// InvalidateParent is an InvalidationRule that invalidates the parent
+// collection of the request URL, such as "/repos/x/issues" after a request to
+// "/repos/x/issues/1".
+func InvalidateParent(req *Request, res *Response) []string {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		return []string{path[:i]}
+	}
+	return nil
+}
+
+// invalidateRelated invalidates the URLs related to the Request's successful
+// response.
+func (r *Request) invalidateRelated(res *Response) {
+	for _, header := range []string{locationHeader, contentLocationHeader} {
+		if u, err := r.URL.Parse(res.Header.Get(header)); err == nil && u.String() != r.URL.String() && sameOrigin(u, r.URL) {
+			r.invalidate(u.String())
+		}
+	}
+
+	for _, rule := range r.Invalidations {
+		for _, target := range rule(r, res) {
+			r.invalidate(target)
+		}
+	}
+}
+
+// invalidate resets the cached GET response for the target URL, or every
+// cached URL starting with the target if it ends in "*".
+func (r *Request) invalidate(target string) {
+	prefix := strings.HasSuffix(target, "*")
+	u, err := r.URL.Parse(strings.TrimSuffix(target, "*"))
+	if err != nil {
+		return
+	}
+
+	if prefix {
+		if invalidator, ok := r.Cacher.(PrefixInvalidator); ok {
+			invalidator.InvalidatePrefix(u.String())
+		}
+		return
+	}
+
+	r.Cacher.Reset(r.cacheTarget(u))
+}
+
+// cacheTarget returns a GET request for the target URL, like one built with
+// the Client's NewRequest(), so that it has the same cache key as the cached
+// GET response.  The Client's query values are merged into the URL, and the
+// Accept header is the Client's default if it has one.  The other headers are
+// copied from the Request, so the cache is partitioned by the same credentials
+// and cookies.  The Request's body headers are removed.
+func (r *Request) cacheTarget(u *url.URL) *http.Request {
+	req := r.Request.Clone(r.Context())
+	req.Method = GetMethod
+	req.URL = u
+	req.Host = ""
+	req.Body = nil
+	req.GetBody = nil
+	req.ContentLength = 0
+
+	for key := range req.Header {
+		if strings.HasPrefix(key, contentHeaderPrefix) {
+			req.Header.Del(key)
+		}
+	}
+
+	if r.client != nil {
+		req.URL = r.client.ResolveReference(u)
+		if accept := r.client.Header.Values(acceptHeader); len(accept) > 0 {
+			req.Header[acceptHeader] = append([]string(nil), accept...)
+		}
+	}
+	return req
+}
+
+func matchPattern(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == path
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
+
+const (
+	acceptHeader          = "Accept"
+	contentHeaderPrefix   = "Content-"
+	locationHeader        = "Location"
+	contentLocationHeader = "Content-Location"
+)
@@ -0,0 +1,143 @@
+package sawyer
+
+import (
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"net/http"
+	"testing"
+)
+
+func TestInvalidateLocation(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/repos/x/issues", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/repos/x/issues/1")
+		w.Header().Set("Content-Location", "http://example.com/repos/x/issues/1")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	cacher := &invalidationCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+
+	req, err := client.NewRequest("/repos/x/issues")
+	assert.Equal(t, nil, err)
+
+	res := req.Post()
+	assert.Equal(t, 201, res.StatusCode)
+
+	// the cross-origin Content-Location is ignored
+	assert.Equal(t, []string{
+		"GET " + setup.Server.URL + "/repos/x/issues?a=1&b=1",
+		"GET " + setup.Server.URL + "/repos/x/issues/1?a=1&b=1",
+	}, cacher.Resets)
+}
+
+func TestInvalidationRules(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/repos/x/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cacher := &invalidationCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+	client.Invalidate(
+		InvalidateParent,
+		InvalidateOn(PatchMethod, "/repos/*", "/repos/x*"),
+		InvalidateOn(PostMethod, "/repos/*", "/never"),
+	)
+
+	req, err := client.NewRequest("/repos/x/issues/1")
+	assert.Equal(t, nil, err)
+
+	res := req.Patch()
+	assert.Equal(t, 204, res.StatusCode)
+
+	assert.Equal(t, []string{
+		"GET " + setup.Server.URL + "/repos/x/issues/1?a=1&b=1",
+		"GET " + setup.Server.URL + "/repos/x/issues?a=1&b=1",
+	}, cacher.Resets)
+	assert.Equal(t, []string{setup.Server.URL + "/repos/x"}, cacher.Prefixes)
+}
+
+func TestInvalidationSkippedOnError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/repos/x/issues", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/repos/x/issues/1")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+
+	cacher := &invalidationCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+	client.Invalidate(InvalidateParent)
+
+	req, err := client.NewRequest("/repos/x/issues")
+	assert.Equal(t, nil, err)
+
+	res := req.Post()
+	assert.Equal(t, 422, res.StatusCode)
+	assert.Equal(t, 0, len(cacher.Resets))
+}
+
+func TestInvalidationTargetHeaders(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/repos/x/issues", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/repos/x/issues/1")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mtype, err := mediatype.Parse("application/json")
+	assert.Equal(t, nil, err)
+
+	cacher := &invalidationCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+	client.Header.Set("Accept", "application/vnd.sawyer+json")
+
+	req, err := client.NewRequest("/repos/x/issues")
+	assert.Equal(t, nil, err)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "token abc")
+	assert.Equal(t, nil, req.SetBody(mtype, &TestUser{Login: "sawyer"}))
+
+	res := req.Post()
+	assert.Equal(t, 201, res.StatusCode)
+
+	// the targets look like GET requests from the client, with the same
+	// credentials
+	assert.Equal(t, 2, len(cacher.Targets))
+	for _, target := range cacher.Targets {
+		assert.Equal(t, GetMethod, target.Method)
+		assert.Equal(t, "application/vnd.sawyer+json", target.Header.Get("Accept"))
+		assert.Equal(t, "token abc", target.Header.Get("Authorization"))
+		assert.Equal(t, "", target.Header.Get("Content-Type"))
+		assert.Equal(t, nil, target.Body)
+	}
+}
+
+type invalidationCacher struct {
+	Resets   []string
+	Prefixes []string
+	Targets  []*http.Request
+	*noOpCache
+}
+
+func (c *invalidationCacher) Reset(req *http.Request) error {
+	c.Resets = append(c.Resets, req.Method+" "+req.URL.String())
+	c.Targets = append(c.Targets, req)
+	return nil
+}
+
+func (c *invalidationCacher) InvalidatePrefix(prefix string) error {
+	c.Prefixes = append(c.Prefixes, prefix)
+	return nil
+}
@@ -1,7 +1,6 @@
 package mediatype
 
 import (
-	"fmt"
 	"io"
 )
 
@@ -26,13 +25,13 @@ func AddDecoder(format string, decfunc DecoderFunc) {
 	decoders[format] = decfunc
 }
 
-// Decoder finds a decoder based on this MediaType's Format field.  An error is
-// returned if a decoder cannot be found.
+// Decoder finds a decoder based on this MediaType's Format field.  An
+// *UnsupportedFormatError is returned if a decoder cannot be found.
 func (m *MediaType) Decoder(body io.Reader) (Decoder, error) {
 	if decfunc, ok := decoders[m.Format]; ok {
 		return decfunc(body), nil
 	}
-	return nil, fmt.Errorf("No decoder found for format %s (%s)", m.Format, m.String())
+	return nil, &UnsupportedFormatError{MediaType: m, Codec: "decoder"}
 }
 
 // Encode uses this MediaType's Decoder to decode the io.Reader into the given
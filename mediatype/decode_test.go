@@ -2,6 +2,7 @@ package mediatype
 
 import (
 	"bytes"
+	"errors"
 	"github.com/bmizerany/assert"
 	"io"
 	"io/ioutil"
@@ -40,6 +41,12 @@ func TestRequiresDecoder(t *testing.T) {
 	if !strings.HasPrefix(err.Error(), "No decoder found for format whatevs") {
 		t.Fatalf("Bad error: %s", err)
 	}
+
+	var formatErr *UnsupportedFormatError
+	assert.Equal(t, true, errors.As(err, &formatErr))
+	assert.Equal(t, mt, formatErr.MediaType)
+	assert.Equal(t, "decoder", formatErr.Codec)
+	assert.Equal(t, true, errors.Is(err, ErrUnsupportedFormat))
 }
 
 func TestSkipsDecoderForNil(t *testing.T) {
@@ -2,7 +2,6 @@ package mediatype
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 )
 
@@ -27,20 +26,20 @@ func AddEncoder(format string, encfunc EncoderFunc) {
 	encoders[format] = encfunc
 }
 
-// Encoder finds an encoder based on this MediaType's Format field.  An error is
-// returned if an encoder cannot be found.
+// Encoder finds an encoder based on this MediaType's Format field.  An
+// *UnsupportedFormatError is returned if an encoder cannot be found.
 func (m *MediaType) Encoder(w io.Writer) (Encoder, error) {
 	if encfunc, ok := encoders[m.Format]; ok {
 		return encfunc(w), nil
 	}
-	return nil, fmt.Errorf("No encoder found for format %s (%s)", m.Format, m.String())
+	return nil, &UnsupportedFormatError{MediaType: m, Codec: "encoder"}
 }
 
 // Encode uses this MediaType's Encoder to encode the given value into a
 // bytes.Buffer.
 func (m *MediaType) Encode(v interface{}) (*bytes.Buffer, error) {
 	if v == nil {
-		return nil, fmt.Errorf("Nothing to encode")
+		return nil, ErrNothingToEncode
 	}
 
 	buf := new(bytes.Buffer)
@@ -4,6 +4,7 @@ package mediatype
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"mime"
 	"strings"
@@ -79,6 +80,28 @@ func (m *MediaType) IsVendor() bool {
 	return len(m.Vendor) > 0
 }
 
+// ErrUnsupportedFormat matches any *UnsupportedFormatError with errors.Is.
+var ErrUnsupportedFormat = errors.New("Unsupported format")
+
+// ErrNothingToEncode is returned when encoding a nil value.
+var ErrNothingToEncode = errors.New("Nothing to encode")
+
+// An UnsupportedFormatError is returned when there is no registered Decoder or
+// Encoder for a MediaType's Format.  Codec is either "decoder" or "encoder".
+type UnsupportedFormatError struct {
+	MediaType *MediaType
+	Codec     string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return "No " + e.Codec + " found for format " + e.MediaType.Format + " (" + e.MediaType.String() + ")"
+}
+
+// Is returns true for ErrUnsupportedFormat.
+func (e *UnsupportedFormatError) Is(target error) bool {
+	return target == ErrUnsupportedFormat
+}
+
 func parse(m *MediaType) (*MediaType, error) {
 	pieces := strings.Split(m.Type, typeSplit)
 	m.MainType = pieces[0]
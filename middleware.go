@@ -0,0 +1,40 @@
+package sawyer
+
+// HandlerFunc performs a sawyer Request and returns the Response.
+type HandlerFunc func(*Request) *Response
+
+// Middleware wraps the handling of a Request.  It receives the Request and the
+// next HandlerFunc in the chain.  A Middleware can modify the Request before
+// calling next, inspect or replace the returned Response, or skip next entirely
+// and return its own Response.
+//
+//	logger := func(req *sawyer.Request, next sawyer.HandlerFunc) *sawyer.Response {
+//	  res := next(req)
+//	  log.Printf("%s %s: %d", req.Method, req.URL, res.StatusCode)
+//	  return res
+//	}
+type Middleware func(req *Request, next HandlerFunc) *Response
+
+// Use appends the given middleware to the Client's chain.  Requests created
+// with NewRequest() get a copy of the chain at that time.  Middleware runs in
+// the order it was added, around the Request's cache lookup and HTTP
+// round-trip.
+func (c *Client) Use(middleware ...Middleware) {
+	c.Middleware = append(c.Middleware, middleware...)
+}
+
+// handler builds the HandlerFunc for the Request's middleware chain.  The
+// first middleware is the outermost.
+func (r *Request) handler() HandlerFunc {
+	h := HandlerFunc((*Request).do)
+	for i := len(r.Middleware) - 1; i >= 0; i-- {
+		h = r.Middleware[i].wrap(h)
+	}
+	return h
+}
+
+func (m Middleware) wrap(next HandlerFunc) HandlerFunc {
+	return func(req *Request) *Response {
+		return m(req, next)
+	}
+}
@@ -0,0 +1,82 @@
+package sawyer
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "a,b", r.Header.Get("X-Middleware"))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	calls := []string{}
+	client := setup.Client
+	client.Use(func(req *Request, next HandlerFunc) *Response {
+		calls = append(calls, "a before")
+		req.Header.Add("X-Middleware", "a")
+		res := next(req)
+		calls = append(calls, "a after")
+		return res
+	}, func(req *Request, next HandlerFunc) *Response {
+		calls = append(calls, "b before")
+		req.Header.Set("X-Middleware", req.Header.Get("X-Middleware")+",b")
+		res := next(req)
+		calls = append(calls, "b after")
+		return res
+	})
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, []string{"a before", "b before", "b after", "a after"}, calls)
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requested := false
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := setup.Client
+	client.Use(func(req *Request, next HandlerFunc) *Response {
+		return &Response{Response: &http.Response{StatusCode: http.StatusTeapot}}
+	})
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, false, requested)
+}
+
+func TestRequestCopiesMiddleware(t *testing.T) {
+	client, err := NewFromString("http://api.github.com", nil)
+	assert.Equal(t, nil, err)
+
+	noop := func(req *Request, next HandlerFunc) *Response {
+		return next(req)
+	}
+	client.Use(noop)
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(req.Middleware))
+
+	client.Use(noop)
+	assert.Equal(t, 2, len(client.Middleware))
+	assert.Equal(t, 1, len(req.Middleware))
+}
@@ -0,0 +1,50 @@
+package sawyer
+
+import (
+	"errors"
+	"net"
+	"net/url"
+)
+
+// offline serves the request from the Cacher without touching the network.
+// Only GET requests can be cached, so other methods always fail.
+func (r *Request) offline() *Response {
+	if r.cacherBehavior() != useCache {
+		return ResponseError(&NotCachedError{Method: r.Method, URL: r.URL.String()})
+	}
+
+	cached, err := r.Cacher.Get(r.Request)
+	if err != nil {
+		return ResponseError(&NotCachedError{Method: r.Method, URL: r.URL.String(), Err: err})
+	}
+
+	res := cached.Decode(r)
+	res.Stale = cached.IsExpired()
+	return res
+}
+
+// transportError returns true if the request failed to reach the server, so
+// that OfflineOnError can serve the cached response.  Errors from the
+// Authenticator or RateLimiter are not transport errors.
+func transportError(err error) bool {
+	var reqErr *RequestError
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &reqErr) || errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// A NotCachedError is set as the ResponseError when an offline request has no
+// cached response.  Err is the Cacher's error, if any.  See Client.Offline.
+type NotCachedError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *NotCachedError) Error() string {
+	return e.Method + " " + e.URL + ": not cached"
+}
+
+func (e *NotCachedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,115 @@
+package sawyer
+
+import (
+	"context"
+	"errors"
+	"github.com/bmizerany/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOfflineNotCached(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Offline request sent: %s %s", r.Method, r.URL)
+	})
+
+	client := setup.Client
+	client.Offline = true
+
+	for _, method := range []string{GetMethod, PostMethod} {
+		req, err := client.NewRequest("/repos")
+		assert.Equal(t, nil, err)
+
+		res := req.Do(method)
+		assert.Equal(t, true, res.IsError())
+
+		var notCached *NotCachedError
+		assert.Equal(t, true, errors.As(res.ResponseError, &notCached))
+		assert.Equal(t, method, notCached.Method)
+		assert.Equal(t, setup.Server.URL+"/repos?a=1&b=1", notCached.URL)
+		assert.Equal(t, method+" "+notCached.URL+": not cached", res.Error())
+	}
+}
+
+func TestOfflineOnErrorTransportErrors(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Rate limited request sent: %s %s", r.Method, r.URL)
+	})
+
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", "1")
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+
+	client := setup.Client
+	client.Cacher = &expiredCacher{&noOpCache{}}
+	client.OfflineOnError = true
+	client.RateLimiter = &RateLimiter{}
+	client.RateLimiter.Update(header)
+
+	// a request held back by the RateLimiter is not served from the cache
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	res := req.Get()
+	var limitErr *RateLimitError
+	assert.Equal(t, true, errors.As(res.ResponseError, &limitErr))
+	assert.Equal(t, false, res.Stale)
+
+	// a request that can't reach the server is
+	client.RateLimiter = nil
+	setup.Server.Close()
+	req, err = client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	res = req.Get()
+	assert.Equal(t, false, res.IsError())
+	assert.Equal(t, true, res.Stale)
+	assert.Equal(t, 1, res.Attempts)
+}
+
+func TestOfflineTokenAuth(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	calls := 0
+	fail := true
+	auth := NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		calls += 1
+		if fail {
+			return "", errors.New("token server unreachable")
+		}
+		return "abc", nil
+	}))
+
+	cacher := &authCacher{noOpCache: &noOpCache{}}
+	client := setup.Client
+	client.Cacher = cacher
+	client.Authenticator = auth
+	client.Offline = true
+
+	// the TokenSource is never called for an offline request
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	res := req.Get()
+	var notCached *NotCachedError
+	assert.Equal(t, true, errors.As(res.ResponseError, &notCached))
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, "", cacher.Authorization)
+
+	// but a token it already fetched is part of the cache key
+	fail = false
+	assert.Equal(t, nil, auth.Refresh(context.Background()))
+	req, err = client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	res = req.Get()
+	assert.Equal(t, true, errors.As(res.ResponseError, &notCached))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "Bearer abc", cacher.Authorization)
+}
@@ -0,0 +1,150 @@
+package sawyer
+
+import (
+	"github.com/lostisland/go-sawyer/hypermedia"
+	"reflect"
+)
+
+// A Pager iterates over the pages of a paginated collection.  It follows the
+// "next" relation from the Link header, or from the decoded page's own
+// relations, such as a HAL "_links.next" object.
+//
+//	pager := client.Paginate(req)
+//	pager.MaxItems = 100
+//	for pager.Next(&repos) {
+//	  // repos is a fresh slice with the current page's items
+//	}
+//	if err := pager.Err(); err != nil {
+//	  // ...
+//	}
+type Pager struct {
+	// MaxPages stops the pager after the given number of pages.  Zero means no
+	// limit.
+	MaxPages int
+
+	// MaxItems stops the pager after the given number of items have been
+	// decoded into slices.  The last page is truncated if needed.  Zero means no
+	// limit.
+	MaxItems int
+
+	client *Client
+	req    *Request
+	res    *Response
+	err    error
+	pages  int
+	items  int
+	done   bool
+}
+
+// Paginate returns a Pager that starts with the given Request.  The Request is
+// reused for every page, keeping its headers, middleware and context.
+func (c *Client) Paginate(req *Request) *Pager {
+	return &Pager{client: c, req: req}
+}
+
+// Next requests the next page and decodes it into value, which is reset to its
+// zero value first.  It returns false when there are no more pages, a limit is
+// reached, or an error occurs.  value must be a non-nil pointer.
+func (p *Pager) Next(value interface{}) bool {
+	if p.done || p.limitReached() {
+		p.done = true
+		return false
+	}
+
+	if rv := reflect.ValueOf(value); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		p.fail(ErrInvalidPageValue)
+		return false
+	}
+
+	p.res = p.req.DoContext(p.req.Context(), GetMethod)
+	if p.res.AnyError() {
+		p.fail(pageError(p.res))
+		return false
+	}
+
+	v := reflect.ValueOf(value).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := p.res.Decode(value); err != nil {
+		p.fail(err)
+		return false
+	}
+
+	p.pages += 1
+	if v.Kind() == reflect.Slice {
+		if remaining := p.MaxItems - p.items; p.MaxItems > 0 && v.Len() > remaining {
+			v.Set(v.Slice(0, remaining))
+		}
+		p.items += v.Len()
+	}
+
+	if err := p.advance(value); err != nil {
+		p.fail(err)
+	}
+
+	return true
+}
+
+// Response returns the Response for the current page.  This is useful for
+// inspecting headers, such as rate limits.
+func (p *Pager) Response() *Response {
+	return p.res
+}
+
+// Err returns the error that stopped the Pager, if any.
+func (p *Pager) Err() error {
+	return p.err
+}
+
+// advance points the Request at the next page, or marks the Pager as done.
+func (p *Pager) advance(value interface{}) error {
+	next := hypermedia.HyperHeaderRelations(p.res.Header, nil)[nextRel]
+	if len(next) == 0 {
+		next = hypermedia.Rels(value)[nextRel]
+	}
+
+	if len(next) == 0 {
+		p.done = true
+		return nil
+	}
+
+	u, err := next.Expand(nil)
+	if err != nil {
+		return err
+	}
+
+	u = p.client.ResolveReference(u)
+	p.req.URL = u
+	p.req.Host = ""
+	p.req.Query = u.Query()
+	p.req.Header.Del(ifNoneMatchHeader)
+	p.req.Header.Del(ifModSinceHeader)
+	return nil
+}
+
+func (p *Pager) limitReached() bool {
+	if p.MaxPages > 0 && p.pages >= p.MaxPages {
+		return true
+	}
+	return p.MaxItems > 0 && p.items >= p.MaxItems
+}
+
+// pageError returns the Response as an error.  An API error response without
+// an error message is wrapped in a StatusError, so Err() never returns an
+// error with an empty message.
+func pageError(res *Response) error {
+	if len(res.Error()) == 0 {
+		return &StatusError{res}
+	}
+	return res
+}
+
+func (p *Pager) fail(err error) {
+	p.err = err
+	p.done = true
+}
+
+const (
+	nextRel           = "next"
+	ifNoneMatchHeader = "If-None-Match"
+	ifModSinceHeader  = "If-Modified-Since"
+)
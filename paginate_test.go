@@ -0,0 +1,170 @@
+package sawyer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/hypermedia"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPaginateLinkHeader(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		head := w.Header()
+		head.Set("Content-Type", "application/json")
+		if page < 3 {
+			head.Set("Link", fmt.Sprintf(`</users?page=%d>; rel="next"`, page+1))
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `[{"id": %d}, {"id": %d}]`, page*10+1, page*10+2)
+	})
+
+	req, err := setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	ids := []int{}
+	var users []TestUser
+	pager := setup.Client.Paginate(req)
+	for pager.Next(&users) {
+		assert.Equal(t, 2, len(users))
+		assert.Equal(t, 200, pager.Response().StatusCode)
+		for _, user := range users {
+			ids = append(ids, user.Id)
+		}
+	}
+
+	assert.Equal(t, nil, pager.Err())
+	assert.Equal(t, []int{11, 12, 21, 22, 31, 32}, ids)
+	assert.Equal(t, false, pager.Next(&users))
+}
+
+func TestPaginateLimits(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		head := w.Header()
+		head.Set("Content-Type", "application/json")
+		head.Set("Link", `</users?page=next>; rel="next"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id": 1}, {"id": 2}]`))
+	})
+
+	req, err := setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	var users []TestUser
+	pager := setup.Client.Paginate(req)
+	pager.MaxPages = 2
+	for pager.Next(&users) {
+	}
+	assert.Equal(t, 2, requests)
+
+	requests = 0
+	req, err = setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	items := 0
+	pager = setup.Client.Paginate(req)
+	pager.MaxItems = 3
+	for pager.Next(&users) {
+		items += len(users)
+	}
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 3, items)
+	assert.Equal(t, 1, len(users))
+}
+
+func TestPaginateHAL(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`{"users": [{"id": 2}], "_links": {}}`))
+			return
+		}
+		w.Write([]byte(`{"users": [{"id": 1}], "_links": {"next": {"href": "/users?page=2"}}}`))
+	})
+
+	req, err := setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	ids := []int{}
+	page := &TestUserPage{}
+	pager := setup.Client.Paginate(req)
+	for pager.Next(page) {
+		for _, user := range page.Users {
+			ids = append(ids, user.Id)
+		}
+	}
+
+	assert.Equal(t, nil, pager.Err())
+	assert.Equal(t, []int{1, 2}, ids)
+}
+
+func TestPaginateError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req, err := setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	var users []TestUser
+	pager := setup.Client.Paginate(req)
+	assert.Equal(t, false, pager.Next(&users))
+	assert.NotEqual(t, nil, pager.Err())
+	assert.Equal(t, 404, pager.Response().StatusCode)
+	assert.Equal(t, "404 Not Found", pager.Err().Error())
+
+	var statusErr *StatusError
+	assert.Equal(t, true, errors.As(pager.Err(), &statusErr))
+	assert.Equal(t, pager.Response(), statusErr.Response)
+}
+
+func TestPaginateInvalidValue(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"login":"sawyer"}]`))
+	})
+
+	req, err := setup.Client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	var users []TestUser
+	var nilUsers *[]TestUser
+	for _, value := range []interface{}{nil, users, nilUsers} {
+		pager := setup.Client.Paginate(req)
+		assert.Equal(t, false, pager.Next(value))
+		assert.Equal(t, ErrInvalidPageValue, pager.Err())
+	}
+	assert.Equal(t, 0, requests)
+}
+
+type TestUserPage struct {
+	Users []TestUser `json:"users"`
+	*hypermedia.HALResource
+}
@@ -0,0 +1,156 @@
+package sawyer
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate limit state reported by an API response.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Exhausted returns true if there are no remaining requests until the Reset
+// time.
+func (l RateLimit) Exhausted() bool {
+	return l.Remaining <= 0 && time.Now().Before(l.Reset)
+}
+
+// RateLimit parses the rate limit from the response headers.  Both the
+// GitHub-style X-RateLimit-* headers and the IETF RateLimit-* headers are
+// supported.  It returns false if the response has neither.
+func (r *Response) RateLimit() (RateLimit, bool) {
+	if r.Response == nil {
+		return RateLimit{}, false
+	}
+	return parseRateLimit(r.Header)
+}
+
+// A RateLimiter records the latest rate limit reported by the API, and holds
+// back requests once the quota is used up.  By default, requests fail fast with
+// a *RateLimitError.  If Wait is set, requests block until the quota resets.
+// Responses served from the cache don't touch the RateLimiter.
+type RateLimiter struct {
+	// Wait makes requests block until the rate limit resets, instead of failing.
+	Wait bool
+
+	// MaxWait is the longest that a request will block.  If the rate limit
+	// resets later than that, the request fails.  Zero means no limit.
+	MaxWait time.Duration
+
+	mutex sync.Mutex
+	limit RateLimit
+	known bool
+}
+
+// RateLimit returns the latest recorded rate limit, or false if no response has
+// reported one.
+func (l *RateLimiter) RateLimit() (RateLimit, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit, l.known
+}
+
+// Update records the rate limit from the given response headers, if any.
+func (l *RateLimiter) Update(header http.Header) {
+	if l == nil {
+		return
+	}
+
+	if limit, ok := parseRateLimit(header); ok {
+		l.mutex.Lock()
+		l.limit = limit
+		l.known = true
+		l.mutex.Unlock()
+	}
+}
+
+// Acquire returns nil if a request can be made now.  If the quota is used up,
+// it either waits for the reset or returns a *RateLimitError.
+func (l *RateLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	limit, known := l.RateLimit()
+	if !known || !limit.Exhausted() {
+		return nil
+	}
+
+	wait := limit.Reset.Sub(time.Now())
+	if !l.Wait || (l.MaxWait > 0 && wait > l.MaxWait) {
+		return &RateLimitError{limit}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// A RateLimitError is returned when a RateLimiter holds back a request.
+type RateLimitError struct {
+	RateLimit RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	return "Rate limit of " + strconv.Itoa(e.RateLimit.Limit) + " exceeded, resets at " + e.RateLimit.Reset.Format(time.RFC3339)
+}
+
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	if remaining := header.Get(ghRemainingHeader); len(remaining) > 0 {
+		limit := RateLimit{
+			Limit:     leadingInt(header.Get(ghLimitHeader)),
+			Remaining: leadingInt(remaining),
+		}
+		if reset := leadingInt(header.Get(ghResetHeader)); reset > 0 {
+			limit.Reset = time.Unix(int64(reset), 0)
+		}
+		return limit, true
+	}
+
+	if remaining := header.Get(ietfRemainingHeader); len(remaining) > 0 {
+		limit := RateLimit{
+			Limit:     leadingInt(header.Get(ietfLimitHeader)),
+			Remaining: leadingInt(remaining),
+		}
+		reset := leadingInt(header.Get(ietfResetHeader))
+		limit.Reset = time.Now().Add(time.Duration(reset) * time.Second)
+		return limit, true
+	}
+
+	return RateLimit{}, false
+}
+
+// leadingInt parses the integer at the start of a header value, ignoring any
+// parameters like the quota policies in "100, 100;w=60".
+func leadingInt(value string) int {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end += 1
+	}
+
+	i, _ := strconv.Atoi(value[:end])
+	return i
+}
+
+const (
+	ghLimitHeader       = "X-RateLimit-Limit"
+	ghRemainingHeader   = "X-RateLimit-Remaining"
+	ghResetHeader       = "X-RateLimit-Reset"
+	ietfLimitHeader     = "RateLimit-Limit"
+	ietfRemainingHeader = "RateLimit-Remaining"
+	ietfResetHeader     = "RateLimit-Reset"
+)
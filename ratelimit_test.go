@@ -0,0 +1,153 @@
+package sawyer
+
+import (
+	"context"
+	"errors"
+	"github.com/bmizerany/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGitHubRateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	res := &Response{Response: &http.Response{Header: http.Header{}}}
+	res.Header.Set("X-RateLimit-Limit", "5000")
+	res.Header.Set("X-RateLimit-Remaining", "4999")
+	res.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	limit, ok := res.RateLimit()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 5000, limit.Limit)
+	assert.Equal(t, 4999, limit.Remaining)
+	assert.Equal(t, reset.Unix(), limit.Reset.Unix())
+	assert.Equal(t, false, limit.Exhausted())
+}
+
+func TestIETFRateLimit(t *testing.T) {
+	res := &Response{Response: &http.Response{Header: http.Header{}}}
+	res.Header.Set("RateLimit-Limit", "100, 100;w=60")
+	res.Header.Set("RateLimit-Remaining", "0")
+	res.Header.Set("RateLimit-Reset", "30")
+
+	limit, ok := res.RateLimit()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 100, limit.Limit)
+	assert.Equal(t, 0, limit.Remaining)
+	assert.Equal(t, true, limit.Exhausted())
+	assert.T(t, limit.Reset.After(time.Now().Add(29*time.Second)))
+}
+
+func TestNoRateLimit(t *testing.T) {
+	res := &Response{Response: &http.Response{Header: http.Header{}}}
+	_, ok := res.RateLimit()
+	assert.Equal(t, false, ok)
+}
+
+func TestRateLimiterFailsFast(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		head := w.Header()
+		head.Set("X-RateLimit-Limit", "1")
+		head.Set("X-RateLimit-Remaining", "0")
+		head.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := setup.Client
+	client.RateLimiter = &RateLimiter{}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+
+	limit, ok := client.RateLimiter.RateLimit()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 0, limit.Remaining)
+
+	res = req.Get()
+	assert.Equal(t, true, res.IsError())
+	assert.Equal(t, 0, res.Attempts)
+	assert.Equal(t, 1, requests)
+
+	var limitErr *RateLimitError
+	assert.Equal(t, true, errors.As(res, &limitErr))
+	assert.Equal(t, 1, limitErr.RateLimit.Limit)
+
+	// the request was never sent, so it is not a RequestError
+	var reqErr *RequestError
+	assert.Equal(t, false, errors.As(res, &reqErr))
+}
+
+func TestRateLimiterWaits(t *testing.T) {
+	limiter := &RateLimiter{Wait: true}
+	header := http.Header{}
+	header.Set("RateLimit-Remaining", "0")
+	header.Set("RateLimit-Reset", "1")
+	limiter.Update(header)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, limiter.Acquire(ctx))
+
+	limiter.MaxWait = time.Millisecond
+	var limitErr *RateLimitError
+	assert.Equal(t, true, errors.As(limiter.Acquire(context.Background()), &limitErr))
+
+	limiter.MaxWait = 0
+	limiter.limit.Reset = time.Now().Add(10 * time.Millisecond)
+	assert.Equal(t, nil, limiter.Acquire(context.Background()))
+}
+
+func TestRateLimiterSkipsCachedResponses(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	client := setup.Client
+	client.Cacher = &freshCacher{noOpCache: &noOpCache{}}
+	client.RateLimiter = &RateLimiter{}
+
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	client.RateLimiter.Update(header)
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, 0, res.Attempts)
+}
+
+type freshCacher struct {
+	*noOpCache
+}
+
+func (c *freshCacher) Get(req *http.Request) (CachedResponse, error) {
+	return &freshResponse{}, nil
+}
+
+type freshResponse struct{}
+
+func (r *freshResponse) Decode(req *Request) *Response {
+	return &Response{Response: &http.Response{StatusCode: 200}}
+}
+
+func (r *freshResponse) SetupRequest(req *http.Request) {}
+
+func (r *freshResponse) IsFresh() bool {
+	return true
+}
+
+func (r *freshResponse) IsExpired() bool {
+	return false
+}
@@ -1,6 +1,7 @@
 package sawyer
 
 import (
+	"context"
 	"github.com/lostisland/go-sawyer/mediatype"
 	"io/ioutil"
 	"net/http"
@@ -8,14 +9,26 @@ import (
 )
 
 // Request is a wrapped net/http Request with a pointer to the net/http Client,
-// MediaType, parsed URI query, and the configured Cacher.  Requests are capable
-// of returning a sawyer Response with Do() or the HTTP verb helpers (Get(),
-// Head(), Post(), etc).
+// MediaType, parsed URI query, the configured Cacher, Authenticator,
+// RetryPolicy and RateLimiter, the API error prototype, the ServeStale and
+// offline options, and the Middleware chain and invalidation rules copied from
+// the Client.  Requests are capable of returning a sawyer Response with Do() or
+// the HTTP verb helpers (Get(), Head(), Post(), etc).
 type Request struct {
-	Client    *http.Client
-	MediaType *mediatype.MediaType
-	Query     url.Values
-	Cacher    Cacher
+	Client         *http.Client
+	MediaType      *mediatype.MediaType
+	Query          url.Values
+	Cacher         Cacher
+	Authenticator  Authenticator
+	Middleware     []Middleware
+	RetryPolicy    *RetryPolicy
+	RateLimiter    *RateLimiter
+	ApiError       error
+	ServeStale     bool
+	Invalidations  []InvalidationRule
+	Offline        bool
+	OfflineOnError bool
+	client         *Client
 	*http.Request
 }
 
@@ -28,15 +41,51 @@ func (c *Client) NewRequest(rawurl string) (*Request, error) {
 		return nil, err
 	}
 
-	return &Request{c.HttpClient, nil, httpreq.URL.Query(), c.Cacher, httpreq}, err
+	middleware := make([]Middleware, len(c.Middleware))
+	copy(middleware, c.Middleware)
+
+	invalidations := make([]InvalidationRule, len(c.Invalidations))
+	copy(invalidations, c.Invalidations)
+
+	return &Request{c.HttpClient, nil, httpreq.URL.Query(), c.Cacher, c.Authenticator, middleware, c.RetryPolicy, c.RateLimiter, c.ApiError, c.ServeStale, invalidations, c.Offline, c.OfflineOnError, c, httpreq}, err
 }
 
 // Do completes the HTTP request, returning a response.  The Request's Cacher is
 // used to return a cached response if available.  Otherwise, the request goes
 // through and fills the cache for future requests.
 func (r *Request) Do(method string) *Response {
+	return r.DoContext(context.Background(), method)
+}
+
+// DoContext is like Do(), but the request is bound to the given context.  If
+// the context is canceled or its deadline passes before the response is
+// decoded, the Response's ResponseError is set to the context's error.  See
+// Response.IsCanceled().
+func (r *Request) DoContext(ctx context.Context, method string) *Response {
 	r.URL.RawQuery = r.Query.Encode()
 	r.Method = method
+	r.Request = r.Request.WithContext(ctx)
+
+	// The credentials are part of the cache key, so they're added before the
+	// middleware chain and the cache lookup.
+	if err := r.authenticateKey(); err != nil {
+		return ResponseError(err)
+	}
+
+	return r.handler()(r)
+}
+
+// do is the final HandlerFunc in the Request's middleware chain.  It checks the
+// Cacher for a fresh response before sending the HTTP request.
+func (r *Request) do() *Response {
+	ctx := r.Context()
+	if err := ctx.Err(); err != nil {
+		return ResponseError(err)
+	}
+
+	if r.Offline {
+		return r.offline()
+	}
 
 	cacher := r.Cacher
 	cacheBehavior := r.cacherBehavior()
@@ -45,28 +94,49 @@ func (r *Request) Do(method string) *Response {
 	}
 
 	cached, cachedErr := cacher.Get(r.Request)
+	if err := ctx.Err(); err != nil {
+		return ResponseError(err)
+	}
+
 	if cachedErr == nil {
 		if cached.IsFresh() {
 			return cached.Decode(r)
+		} else if r.staleWhileRevalidate(cached) {
+			r.revalidate()
+			return staleResponse(r, cached, 0)
 		} else {
 			cached.SetupRequest(r.Request)
 		}
 	}
 
-	httpres, err := r.Client.Do(r.Request)
-	if err != nil {
-		return ResponseError(err)
+	httpres, attempts, err := r.roundTrip()
+	if httpres == nil {
+		if err != ctx.Err() {
+			if cachedErr == nil && (r.staleIfError(cached) || (r.OfflineOnError && transportError(err))) {
+				return staleResponse(r, cached, attempts)
+			}
+		}
+		res := ResponseError(err)
+		res.Attempts = attempts
+		return res
 	}
 
 	if cachedErr == nil && cacheBehavior == useCache && httpres.StatusCode == 304 {
 		cacher.UpdateCache(r.Request, httpres)
-		return cached.Decode(r)
+		res := cached.Decode(r)
+		res.Attempts = attempts
+		return res
 	}
 
-	mtype, err := mediaType(httpres)
-	if err != nil {
+	if cachedErr == nil && staleErrorStatus(httpres.StatusCode) && r.staleIfError(cached) {
 		httpres.Body.Close()
-		return ResponseError(err)
+		return staleResponse(r, cached, attempts)
+	}
+
+	mtype, mtypeErr := mediaType(httpres)
+	if mtypeErr != nil {
+		httpres.Body.Close()
+		return ResponseError(mtypeErr)
 	}
 
 	res := &Response{
@@ -74,14 +144,27 @@ func (r *Request) Do(method string) *Response {
 		BodyClosed: false,
 		Response:   httpres,
 		Cacher:     cacher,
+		Attempts:   attempts,
 		isApiError: UseApiError(httpres.StatusCode),
 	}
 
+	if res.isApiError && r.ApiError != nil {
+		res.decodeApiError(r.ApiError)
+	}
+
+	// A 401 response is returned with an error if the Authenticator couldn't
+	// refresh its credentials.
+	if res.ResponseError == nil {
+		res.ResponseError = err
+	}
+
 	if !res.AnyError() {
 		if cacheBehavior == resetCache {
-			r.Cacher.Reset(r.Request)
+			r.Cacher.Reset(r.cacheTarget(r.URL))
+			r.invalidateRelated(res)
 		} else if cacheBehavior == clearCache {
-			r.Cacher.Clear(r.Request)
+			r.Cacher.Clear(r.cacheTarget(r.URL))
+			r.invalidateRelated(res)
 		} else {
 			cacher.Set(r.Request, res)
 		}
@@ -95,40 +178,75 @@ func (r *Request) Head() *Response {
 	return r.Do(HeadMethod)
 }
 
+// HeadContext is a helper method for DoContext().
+func (r *Request) HeadContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, HeadMethod)
+}
+
 // Get is a helper method for Do().
 func (r *Request) Get() *Response {
 	return r.Do(GetMethod)
 }
 
+// GetContext is a helper method for DoContext().
+func (r *Request) GetContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, GetMethod)
+}
+
 // Post is a helper method for Do().
 func (r *Request) Post() *Response {
 	return r.Do(PostMethod)
 }
 
+// PostContext is a helper method for DoContext().
+func (r *Request) PostContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, PostMethod)
+}
+
 // Put is a helper method for Do().
 func (r *Request) Put() *Response {
 	return r.Do(PutMethod)
 }
 
+// PutContext is a helper method for DoContext().
+func (r *Request) PutContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, PutMethod)
+}
+
 // Patch is a helper method for Do().
 func (r *Request) Patch() *Response {
 	return r.Do(PatchMethod)
 }
 
+// PatchContext is a helper method for DoContext().
+func (r *Request) PatchContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, PatchMethod)
+}
+
 // Delete is a helper method for Do().
 func (r *Request) Delete() *Response {
 	return r.Do(DeleteMethod)
 }
 
+// DeleteContext is a helper method for DoContext().
+func (r *Request) DeleteContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, DeleteMethod)
+}
+
 // Options is a helper method for Do().
 func (r *Request) Options() *Response {
 	return r.Do(OptionsMethod)
 }
 
+// OptionsContext is a helper method for DoContext().
+func (r *Request) OptionsContext(ctx context.Context) *Response {
+	return r.DoContext(ctx, OptionsMethod)
+}
+
 // SetBody encodes and sets the proper headers for the request body from the
 // given resource.  The resource is encoded in-memory, so be careful about
-// passing a massive object.  You can set the ContentLength and Body properties
-// manually.
+// passing a massive object.  The body is replayed if the request is retried.
+// You can set the ContentLength and Body properties manually.
 func (r *Request) SetBody(mtype *mediatype.MediaType, resource interface{}) error {
 	r.MediaType = mtype
 	r.Header.Set(ctypeHeader, mtype.String())
@@ -144,6 +262,7 @@ func (r *Request) SetBody(mtype *mediatype.MediaType, resource interface{}) erro
 
 	r.ContentLength = int64(buf.Len())
 	r.Body = ioutil.NopCloser(buf)
+	r.GetBody = replayableBody(buf.Bytes())
 	return nil
 }
 
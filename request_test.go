@@ -1,13 +1,17 @@
 package sawyer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/bmizerany/assert"
 	"github.com/lostisland/go-sawyer/hypermedia"
 	"github.com/lostisland/go-sawyer/mediatype"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 // see sawyer_test.go for definitions of structs and SetupServer
@@ -195,6 +199,120 @@ func TestErrorResponse(t *testing.T) {
 	assert.Equal(t, true, res.BodyClosed)
 }
 
+func TestDecodedApiError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
+		head := w.Header()
+		head.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "not found"}`))
+	})
+
+	client := setup.Client
+	client.ApiError = &TestError{}
+
+	req, err := client.NewRequest("404")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, true, res.IsApiError())
+	assert.Equal(t, false, res.IsError())
+	assert.Equal(t, true, res.BodyClosed)
+	assert.Equal(t, "not found", res.Error())
+
+	var apierr *TestError
+	assert.Equal(t, true, errors.As(res.ApiError(), &apierr))
+	assert.Equal(t, "not found", apierr.Message)
+
+	// the prototype is not modified
+	assert.Equal(t, "", client.ApiError.(*TestError).Message)
+}
+
+func TestValueApiError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
+		head := w.Header()
+		head.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "not found"}`))
+	})
+
+	client := setup.Client
+	client.ApiError = TestValueError{}
+
+	req, err := client.NewRequest("404")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, true, res.IsApiError())
+	assert.Equal(t, "not found", res.Error())
+
+	var apierr TestValueError
+	assert.Equal(t, true, errors.As(res.ApiError(), &apierr))
+	assert.Equal(t, "not found", apierr.Message)
+}
+
+func TestEmptyApiError(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// a chunked response has no Content-Length
+	setup.Mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.(http.Flusher).Flush()
+	})
+
+	client := setup.Client
+	client.ApiError = &TestError{}
+
+	for _, path := range []string{"404", "chunked"} {
+		for _, method := range []string{GetMethod, HeadMethod} {
+			req, err := client.NewRequest(path)
+			assert.Equal(t, nil, err)
+
+			res := req.Do(method)
+			assert.Equalf(t, true, res.IsApiError(), "%s %s", method, path)
+			assert.Equalf(t, nil, res.ResponseError, "%s %s", method, path)
+			assert.Equalf(t, nil, res.ApiError(), "%s %s", method, path)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		}
+	}
+}
+
+func TestApiErrorPerRequest(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		head := w.Header()
+		head.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"login": "sawyer"}`))
+	})
+
+	req, err := setup.Client.NewRequest("user")
+	assert.Equal(t, nil, err)
+	req.ApiError = &TestError{}
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, nil, res.ApiError())
+
+	user := &TestUser{HALResource: &hypermedia.HALResource{}}
+	assert.Equal(t, nil, res.Decode(user))
+	assert.Equal(t, "sawyer", user.Login)
+}
+
 func TestResolveRequestQuery(t *testing.T) {
 	setup := Setup(t)
 	defer setup.Teardown()
@@ -228,3 +346,102 @@ func TestResolveRequestQuery(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, 123, res.StatusCode)
 }
+
+func TestCanceledGet(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requested := false
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, err := setup.Client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res := req.GetContext(ctx)
+	assert.Equal(t, true, res.IsError())
+	assert.Equal(t, true, res.IsCanceled())
+	assert.Equal(t, context.Canceled, res.ResponseError)
+	assert.Equal(t, false, requested)
+}
+
+func TestGetDeadlineExceeded(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	done := make(chan struct{})
+	defer close(done)
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	})
+
+	req, err := setup.Client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	res := req.GetContext(ctx)
+	assert.Equal(t, true, res.IsCanceled())
+	assert.Equal(t, context.DeadlineExceeded, res.ResponseError)
+}
+
+func TestDecodeCanceled(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": 1, "login": "sawyer"}`))
+	})
+
+	req, err := setup.Client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := req.GetContext(ctx)
+	assert.Equal(t, false, res.IsError())
+	cancel()
+
+	user := &TestUser{}
+	assert.Equal(t, context.Canceled, res.Decode(user))
+	assert.Equal(t, true, res.IsCanceled())
+	assert.Equal(t, "", user.Login)
+}
+
+func TestRequestError(t *testing.T) {
+	setup := Setup(t)
+	url := setup.Server.URL
+	setup.Teardown()
+
+	client, err := NewFromString(url, nil)
+	assert.Equal(t, nil, err)
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, true, res.IsError())
+	assert.Equal(t, false, res.IsCanceled())
+
+	var reqErr *RequestError
+	assert.Equal(t, true, errors.As(res, &reqErr))
+	assert.Equal(t, "GET", reqErr.Method)
+	assert.Equal(t, url+"/user", reqErr.URL)
+	assert.NotEqual(t, nil, errors.Unwrap(reqErr))
+
+	// the *url.Error from the net/http Client is kept
+	var urlErr *neturl.Error
+	assert.Equal(t, true, errors.As(res, &urlErr))
+	assert.Equal(t, "Get", urlErr.Op)
+	assert.Equal(t, false, strings.Contains(reqErr.Error(), `Get "`))
+}
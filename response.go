@@ -1,15 +1,21 @@
 package sawyer
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"github.com/lostisland/go-sawyer/hypermedia"
 	"github.com/lostisland/go-sawyer/mediatype"
 	"io"
 	"net/http"
+	"reflect"
 )
 
 // Response is a wrapped net/http Response with a pointer to the MediaType and
-// the cacher.  It also doubles as a possible error object.
+// the cacher.  It also doubles as a possible error object.  Attempts is the
+// number of HTTP requests made for the response, which is 0 if it was served
+// from the cache.  Stale is true if an expired cached response was served.  See
+// Client.ServeStale and Client.Offline.
 type Response struct {
 	// ResponseError stores any errors made making the HTTP request.  If set, then
 	// AnyError() and IsError() will return true, and Error() will delegate to it.
@@ -17,7 +23,10 @@ type Response struct {
 	MediaType     *mediatype.MediaType
 	BodyClosed    bool
 	Cacher        Cacher
+	Attempts      int
+	Stale         bool
 	isApiError    bool
+	apiError      error
 	rels          hypermedia.Relations
 	*http.Response
 }
@@ -33,39 +42,85 @@ func (r *Response) IsError() bool {
 	return r.ResponseError != nil
 }
 
+// IsCanceled returns true if the HTTP request failed because its context was
+// canceled or its deadline passed.
+func (r *Response) IsCanceled() bool {
+	return errors.Is(r.ResponseError, context.Canceled) || errors.Is(r.ResponseError, context.DeadlineExceeded)
+}
+
 // IsApiError returns true if the response status is not a 2xx code.
 func (r *Response) IsApiError() bool {
 	return r.isApiError
 }
 
-// Error returns the ResponseError's error string if set, or an empty string.
+// ApiError returns the API error decoded from the response body, or nil.  It is
+// only set if the Request has an ApiError prototype and the response status is
+// not a 2xx code.  The returned value has the same type as the prototype, so
+// it can be matched with errors.As.
+//
+//	res := req.Get()
+//	var apierr *ApiError
+//	if errors.As(res.ApiError(), &apierr) {
+//	  log.Println(apierr.Message)
+//	}
+func (r *Response) ApiError() error {
+	return r.apiError
+}
+
+// Unwrap returns the ResponseError, so that errors.Is and errors.As can inspect
+// a Response used as an error.
+func (r *Response) Unwrap() error {
+	return r.ResponseError
+}
+
+// Error returns the ResponseError's error string if set, the decoded API
+// error's string if set, or an empty string.
 func (r *Response) Error() string {
 	if r.ResponseError != nil {
 		return r.ResponseError.Error()
 	}
+	if r.apiError != nil {
+		return r.apiError.Error()
+	}
 	return ""
 }
 
 // Decode will decode the body into the given resource, and parse the hypermedia
 // relations.  This is meant to be called after an HTTP request, and will close
-// the response body.  The decoder is set from the response's MediaType.
+// the response body.  The decoder is set from the response's MediaType.  If
+// the request's context is done, decoding stops with the context's error.
 func (r *Response) Decode(resource interface{}) error {
 	if r.BodyClosed {
-		return errors.New("Body is already closed")
+		return ErrBodyClosed
 	}
 
 	if r.MediaType == nil {
-		return errors.New("No media type for this response")
+		return ErrNoMediaType
 	}
 
 	if r.ResponseError != nil {
-		return errors.New("Existing Response error")
+		return ErrExistingResponseError
 	}
 
 	defer r.Body.Close()
 	r.BodyClosed = true
 
-	r.ResponseError = r.DecodeFrom(resource, r.Body)
+	ctx := r.context()
+	if err := ctx.Err(); err != nil {
+		r.ResponseError = err
+		return err
+	}
+
+	var body io.Reader = r.Body
+	if ctx.Done() != nil {
+		body = &contextReader{ctx, r.Body}
+	}
+
+	r.ResponseError = r.DecodeFrom(resource, body)
+	if r.ResponseError != nil && ctx.Err() != nil {
+		r.ResponseError = ctx.Err()
+	}
+
 	if r.ResponseError == nil {
 		rels := hypermedia.Rels(resource)
 		if err := r.Cacher.SetRels(r.Request, rels); err == nil {
@@ -79,10 +134,11 @@ func (r *Response) Decode(resource interface{}) error {
 }
 
 // DecodeFrom decodes the resource from the given io.Reader, using the decoder
-// from the response's MediaType.
+// from the response's MediaType.  A *mediatype.UnsupportedFormatError is
+// returned if there is no decoder, and a *DecodeError if decoding fails.
 func (r *Response) DecodeFrom(resource interface{}, body io.Reader) error {
 	if resource == nil {
-		return errors.New("No resource")
+		return ErrNoResource
 	}
 
 	dec, err := r.MediaType.Decoder(body)
@@ -91,12 +147,53 @@ func (r *Response) DecodeFrom(resource interface{}, body io.Reader) error {
 	}
 
 	if err := dec.Decode(resource); err != nil {
-		return err
+		return &DecodeError{MediaType: r.MediaType, Err: err}
 	}
 
 	return nil
 }
 
+// decodeApiError decodes the body into a new value of the prototype's type, and
+// closes the body.  The ResponseError is set if the body can't be decoded.  A
+// pointer prototype gets a new pointer, and a value prototype gets a new value
+// of the same type, so the result implements error like the prototype does.  A
+// response without a body, such as the response to a HEAD request, has no API
+// error.
+func (r *Response) decodeApiError(prototype error) {
+	if r.MediaType == nil || r.BodyClosed {
+		return
+	}
+
+	defer r.Body.Close()
+	r.BodyClosed = true
+
+	if r.ContentLength == 0 || (r.Request != nil && r.Request.Method == HeadMethod) {
+		return
+	}
+
+	body := bufio.NewReader(r.Body)
+	if _, err := body.Peek(1); err == io.EOF {
+		return
+	}
+
+	t := reflect.TypeOf(prototype)
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+
+	value := reflect.New(t)
+	if err := r.DecodeFrom(value.Interface(), body); err != nil {
+		r.ResponseError = err
+		return
+	}
+
+	if !isPtr {
+		value = value.Elem()
+	}
+	r.apiError, _ = value.Interface().(error)
+}
+
 // HypermediaRels implements the hypermedia.HypermediaResource interface.  The
 // relations are parsed from the Link header.
 func (r *Response) HypermediaRels(rels hypermedia.Relations) {
@@ -141,6 +238,28 @@ func UseApiError(status int) bool {
 	return true
 }
 
+// context returns the context of the request that made this response.
+func (r *Response) context() context.Context {
+	if r.Response != nil && r.Request != nil {
+		return r.Request.Context()
+	}
+	return context.Background()
+}
+
+// contextReader stops reading from the wrapped io.Reader once its context is
+// done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
 func mediaType(res *http.Response) (*mediatype.MediaType, error) {
 	if ctype := res.Header.Get(ctypeHeader); len(ctype) > 0 {
 		return mediatype.Parse(ctype)
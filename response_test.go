@@ -1,8 +1,11 @@
 package sawyer
 
 import (
+	"bytes"
 	"errors"
 	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"io/ioutil"
 	"net/http"
 	"testing"
 )
@@ -37,3 +40,58 @@ func TestApiErroringResponse(t *testing.T) {
 	assert.Equal(t, "", r.Error())
 	assert.Equal(t, 404, r.StatusCode)
 }
+
+func TestDecodeSentinelErrors(t *testing.T) {
+	r := &Response{Response: &http.Response{StatusCode: 200}, BodyClosed: true}
+	assert.Equal(t, ErrBodyClosed, r.Decode(&TestUser{}))
+
+	r = &Response{Response: &http.Response{StatusCode: 200}}
+	assert.Equal(t, ErrNoMediaType, r.Decode(&TestUser{}))
+
+	mtype, err := mediatype.Parse("application/json")
+	assert.Equal(t, nil, err)
+
+	r = &Response{ResponseError: errors.New("wat"), MediaType: mtype, Response: &http.Response{}}
+	assert.Equal(t, ErrExistingResponseError, r.Decode(&TestUser{}))
+	assert.Equal(t, ErrNoResource, r.DecodeFrom(nil, nil))
+}
+
+func TestDecodeError(t *testing.T) {
+	mtype, err := mediatype.Parse("application/json")
+	assert.Equal(t, nil, err)
+
+	r := &Response{
+		MediaType: mtype,
+		Cacher:    noOpCacher,
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("{")),
+		},
+	}
+
+	err = r.Decode(&TestUser{})
+	var decodeErr *DecodeError
+	assert.Equal(t, true, errors.As(err, &decodeErr))
+	assert.Equal(t, mtype, decodeErr.MediaType)
+	assert.Equal(t, true, errors.As(r, &decodeErr))
+}
+
+func TestUnsupportedFormatError(t *testing.T) {
+	mtype, err := mediatype.Parse("application/booya+booya")
+	assert.Equal(t, nil, err)
+
+	r := &Response{
+		MediaType: mtype,
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("{}")),
+		},
+	}
+
+	err = r.Decode(&TestUser{})
+	assert.Equal(t, true, errors.Is(err, mediatype.ErrUnsupportedFormat))
+
+	var formatErr *mediatype.UnsupportedFormatError
+	assert.Equal(t, true, errors.As(r, &formatErr))
+	assert.Equal(t, "booya", formatErr.MediaType.Format)
+}
@@ -0,0 +1,264 @@
+package sawyer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A RetryPolicy describes when and how a failed Request is sent again.  Only
+// idempotent methods are retried, unless RetryUnsafe is set.  Requests with a
+// body are only retried if the body can be replayed, such as a body set with
+// SetBody().
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.  A
+	// value less than 2 disables retries.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry.  The delay doubles with
+	// each attempt, up to MaxBackoff.  Random jitter of up to half the delay is
+	// applied.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.  If a Retry-After header asks
+	// for a longer delay, the response is returned without retrying.  Zero means
+	// no limit.
+	MaxBackoff time.Duration
+
+	// Statuses lists the response status codes that are retried.  If nil,
+	// DefaultRetryStatuses is used.
+	Statuses []int
+
+	// RetryError reports whether the given error from the net/http Client is
+	// retryable.  If nil, all errors are retried unless the request's context
+	// is done.
+	RetryError func(error) bool
+
+	// RetryUnsafe allows retries of non-idempotent methods, like POST and PATCH.
+	RetryUnsafe bool
+}
+
+// DefaultRetryStatuses are the response status codes retried by a RetryPolicy
+// with no Statuses.
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// roundTrip sends the Request with the net/http Client, retrying according to
+// the Request's RetryPolicy.  The Request's Authenticator adds its credentials
+// and the Request's RateLimiter is consulted before each attempt.  A 401
+// response is retried once if the Authenticator can refresh its credentials.
+// If the refresh fails, the 401 response is returned with the refresh error.
+// It returns the number of requests sent, so an attempt rejected by the
+// Authenticator or RateLimiter isn't counted.  Errors from the net/http Client
+// are wrapped in a *RequestError.
+func (r *Request) roundTrip() (*http.Response, int, error) {
+	ctx := r.Context()
+	attempts := 1
+	refreshed := false
+	for {
+		if err := r.authenticate(); err != nil {
+			return nil, attempts - 1, err
+		}
+
+		if err := r.RateLimiter.Acquire(ctx); err != nil {
+			return nil, attempts - 1, err
+		}
+
+		httpres, err := r.Client.Do(r.Request)
+		if err == nil {
+			r.RateLimiter.Update(httpres.Header)
+
+			if !refreshed {
+				retry, authErr := r.refreshAuth(httpres)
+				if authErr != nil {
+					return httpres, attempts, authErr
+				}
+
+				if retry {
+					io.Copy(ioutil.Discard, httpres.Body)
+					httpres.Body.Close()
+					if err := r.rewindBody(); err != nil {
+						return nil, attempts, err
+					}
+
+					refreshed = true
+					attempts += 1
+					continue
+				}
+			}
+		}
+
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return nil, attempts, ctxErr
+		}
+
+		delay, retry := r.RetryPolicy.backoff(r, httpres, err, attempts)
+		if !retry {
+			if err != nil {
+				err = requestError(r, err)
+			}
+			return httpres, attempts, err
+		}
+
+		if httpres != nil {
+			io.Copy(ioutil.Discard, httpres.Body)
+			httpres.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempts, ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := r.rewindBody(); err != nil {
+			return nil, attempts, err
+		}
+		attempts += 1
+	}
+}
+
+// backoff determines if the Request should be retried after the given attempt,
+// and how long to wait first.
+func (p *RetryPolicy) backoff(req *Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if !p.RetryUnsafe && !isIdempotent(req.Method) {
+		return 0, false
+	}
+
+	if req.Body != nil && req.GetBody == nil {
+		return 0, false
+	}
+
+	if err != nil {
+		if p.RetryError != nil && !p.RetryError(err) {
+			return 0, false
+		}
+		return p.delay(attempt), true
+	}
+
+	if !p.retryStatus(res.StatusCode) {
+		return 0, false
+	}
+
+	if wait, ok := retryAfter(res.Header.Get(retryAfterHeader)); ok {
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	return p.delay(attempt), true
+}
+
+// delay returns the exponential backoff with jitter for the given attempt.
+// Without a MaxBackoff, the delay stops doubling before it overflows.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt; i++ {
+		if d > maxDelay/2 {
+			d = maxDelay
+			break
+		}
+
+		d *= 2
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
+
+func (p *RetryPolicy) retryStatus(status int) bool {
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// rewindBody resets the request body before another attempt.
+func (r *Request) rewindBody() error {
+	if r.GetBody == nil {
+		return nil
+	}
+
+	body, err := r.GetBody()
+	if err == nil {
+		r.Body = body
+	}
+	return err
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or an HTTP date.
+func retryAfter(header string) (time.Duration, bool) {
+	if len(header) == 0 {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(time.Now()); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case GetMethod, HeadMethod, OptionsMethod, PutMethod, DeleteMethod:
+		return true
+	}
+	return false
+}
+
+// replayableBody returns a GetBody func for http.Request that returns a new
+// reader for the given bytes.
+func replayableBody(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+const (
+	retryAfterHeader = "Retry-After"
+	maxDelay         = time.Duration(math.MaxInt64)
+)
@@ -0,0 +1,224 @@
+package sawyer
+
+import (
+	"github.com/bmizerany/assert"
+	"github.com/lostisland/go-sawyer/mediatype"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryStatus(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := setup.Client
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, 3, res.Attempts)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	client := setup.Client
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, true, res.IsApiError())
+	assert.Equal(t, 502, res.StatusCode)
+	assert.Equal(t, 2, res.Attempts)
+	assert.Equal(t, 2, requests)
+}
+
+func TestRetrySkipsUnsafeMethods(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	client := setup.Client
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}
+
+	req, err := client.NewRequest("users")
+	assert.Equal(t, nil, err)
+
+	res := req.Post()
+	assert.Equal(t, 503, res.StatusCode)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	mtype, err := mediatype.Parse("application/json")
+	assert.Equal(t, nil, err)
+
+	logins := []string{}
+	setup.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		user := &TestUser{}
+		mtype.Decode(user, r.Body)
+		logins = append(logins, user.Login)
+
+		if len(logins) < 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	client := setup.Client
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Hour, RetryUnsafe: true}
+
+	req, err := client.NewRequest("users")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, req.SetBody(mtype, &TestUser{Login: "sawyer"}))
+
+	res := req.Post()
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, 2, res.Attempts)
+	assert.Equal(t, []string{"sawyer", "sawyer"}, logins)
+}
+
+func TestRetryAfterLongerThanMaxBackoff(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	client := setup.Client
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Second}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	wait, ok := retryAfter("30")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 30*time.Second, wait)
+
+	wait, ok = retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	wait, ok = retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(t, true, ok)
+	assert.T(t, wait > 59*time.Minute)
+
+	_, ok = retryAfter("soon")
+	assert.Equal(t, false, ok)
+}
+
+func TestRetryBackoffDelay(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	d := policy.delay(1)
+	assert.T(t, d >= 50*time.Millisecond && d <= 100*time.Millisecond)
+
+	d = policy.delay(2)
+	assert.T(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond)
+
+	d = policy.delay(5)
+	assert.T(t, d >= 150*time.Millisecond && d <= 300*time.Millisecond)
+}
+
+func TestRetryBackoffDelayOverflow(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: time.Second}
+
+	for _, attempt := range []int{40, 64, 100} {
+		d := policy.delay(attempt)
+		assert.Tf(t, d >= maxDelay/2, "attempt %d: %s", attempt, d)
+	}
+}
+
+func TestRetryNotModifiedAttempts(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	requests := 0
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	client := setup.Client
+	client.Cacher = &expiredCacher{noOpCache: &noOpCache{}}
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3}
+
+	req, err := client.NewRequest("user")
+	assert.Equal(t, nil, err)
+
+	res := req.Get()
+	assert.Equal(t, false, res.AnyError())
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, 2, res.Attempts)
+}
+
+type expiredCacher struct {
+	*noOpCache
+}
+
+func (c *expiredCacher) Get(req *http.Request) (CachedResponse, error) {
+	return &expiredResponse{}, nil
+}
+
+type expiredResponse struct {
+	freshResponse
+}
+
+func (r *expiredResponse) IsFresh() bool {
+	return false
+}
+
+func (r *expiredResponse) IsExpired() bool {
+	return true
+}
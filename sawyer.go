@@ -8,13 +8,42 @@ import (
 )
 
 // A Client wraps an *http.Client with a base url Endpoint and common header and
-// query values.
+// query values.  The Authenticator, RetryPolicy and RateLimiter are optional,
+// and copied to new Requests.  A RateLimiter is shared by all of the Client's
+// Requests.
+//
+// ApiError is an optional prototype for API errors.  Response bodies with a
+// non-2xx status are decoded into a new value of the same type.  See
+// Response.ApiError().
+//
+// ServeStale allows expired cached responses to be served, if their
+// "stale-while-revalidate" or "stale-if-error" directives permit it.  See
+// Response.Stale.
+//
+// Invalidations are rules for the cached URLs to invalidate after unsafe
+// requests.  See Client.Invalidate().
+//
+// Offline serves every request from the Cacher, whatever the freshness of the
+// cached response, and never touches the network, so a TokenAuth's TokenSource
+// isn't called.  Requests without a cached response fail with a
+// *NotCachedError.  OfflineOnError only falls back to an
+// expired cached response, flagged as Stale, when the request fails with a
+// network error, such as a *RequestError.
 type Client struct {
-	HttpClient *http.Client
-	Endpoint   *url.URL
-	Header     http.Header
-	Query      url.Values
-	Cacher     Cacher
+	HttpClient     *http.Client
+	Endpoint       *url.URL
+	Header         http.Header
+	Query          url.Values
+	Cacher         Cacher
+	Authenticator  Authenticator
+	Middleware     []Middleware
+	RetryPolicy    *RetryPolicy
+	RateLimiter    *RateLimiter
+	ApiError       error
+	ServeStale     bool
+	Invalidations  []InvalidationRule
+	Offline        bool
+	OfflineOnError bool
 }
 
 // New returns a new Client with a given a URL and an optional client.
@@ -27,7 +56,13 @@ func New(endpoint *url.URL, client *http.Client) *Client {
 		endpoint.Path = endpoint.Path + "/"
 	}
 
-	return &Client{client, endpoint, make(http.Header), endpoint.Query(), noOpCacher}
+	return &Client{
+		HttpClient: client,
+		Endpoint:   endpoint,
+		Header:     make(http.Header),
+		Query:      endpoint.Query(),
+		Cacher:     noOpCacher,
+	}
 }
 
 // NewFromString returns a new Client given a string URL and an optional client.
@@ -62,8 +97,13 @@ func (c *Client) ResolveReferenceString(rawurl string) (string, error) {
 
 // Rels attempts to get the cached relations for the given request.  If it
 // hasn't been cached, send a GET to the request URL, decode the response body
-// to the given value, and get the relations from the value.
+// to the given value, and get the relations from the value.  The request is
+// authenticated first, since the relations are cached under its credentials.
 func (c *Client) Rels(req *Request, value interface{}) (hypermedia.Relations, *Response) {
+	if err := req.authenticateKey(); err != nil {
+		return nil, ResponseError(err)
+	}
+
 	if rels, ok := c.Cacher.Rels(req.Request); ok {
 		return rels, &Response{}
 	}
@@ -82,10 +122,15 @@ func buildRequest(c *Client, rawurl string) (*http.Request, error) {
 	}
 
 	httpreq, err := http.NewRequest(GetMethod, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	for key, _ := range c.Header {
 		httpreq.Header.Set(key, c.Header.Get(key))
 	}
-	return httpreq, err
+
+	return httpreq, nil
 }
 
 // mergeQueries merges the given url.Values into a single encoded URI query
@@ -192,6 +192,18 @@ type TestError struct {
 	Message string `json:"message"`
 }
 
+func (e *TestError) Error() string {
+	return e.Message
+}
+
+type TestValueError struct {
+	Message string `json:"message"`
+}
+
+func (e TestValueError) Error() string {
+	return e.Message
+}
+
 type SetupServer struct {
 	Client *Client
 	Server *httptest.Server
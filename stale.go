@@ -0,0 +1,110 @@
+package sawyer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// staleWhileRevalidate returns true if the expired cached response can be
+// served while it is revalidated in the background.
+func (r *Request) staleWhileRevalidate(cached CachedResponse) bool {
+	stale, ok := cached.(StaleCachedResponse)
+	return ok && r.ServeStale && stale.StaleWhileRevalidate()
+}
+
+// staleIfError returns true if the expired cached response can be served after
+// the request failed.
+func (r *Request) staleIfError(cached CachedResponse) bool {
+	stale, ok := cached.(StaleCachedResponse)
+	return ok && r.ServeStale && stale.StaleIfError()
+}
+
+// revalidate refreshes the cached response in the background, with a copy of
+// the Request that is not bound to its context.  The copy goes through the
+// Request's middleware chain.  If the Cacher is a KeyedCacher, a response that
+// is already being revalidated isn't revalidated again.
+func (r *Request) revalidate() {
+	key, keyed := r.revalidationKey()
+	if keyed && !revalidations.start(key) {
+		return
+	}
+
+	req := *r
+	req.Request = r.Request.Clone(context.Background())
+	req.ServeStale = false
+	go func() {
+		if keyed {
+			defer revalidations.finish(key)
+		}
+		req.refresh()
+	}()
+}
+
+// refresh sends the Request through its middleware chain.  Without
+// ServeStale, the expired cached response is revalidated with a conditional
+// request, and the cache is updated with the response.
+func (r *Request) refresh() {
+	res := r.handler()(r)
+	if !res.BodyClosed && res.Body != nil {
+		res.Body.Close()
+	}
+}
+
+func (r *Request) revalidationKey() (revalidationKey, bool) {
+	keyed, ok := r.Cacher.(KeyedCacher)
+	if !ok {
+		return revalidationKey{}, false
+	}
+	return revalidationKey{r.Cacher, keyed.CacheKey(r.Request)}, true
+}
+
+type revalidationKey struct {
+	cacher Cacher
+	key    string
+}
+
+// inFlight tracks the cache keys of the background revalidations.
+type inFlight struct {
+	mutex sync.Mutex
+	keys  map[revalidationKey]bool
+}
+
+var revalidations = &inFlight{keys: make(map[revalidationKey]bool)}
+
+// start returns false if the key is already in flight.
+func (f *inFlight) start(key revalidationKey) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.keys[key] {
+		return false
+	}
+	f.keys[key] = true
+	return true
+}
+
+func (f *inFlight) finish(key revalidationKey) {
+	f.mutex.Lock()
+	delete(f.keys, key)
+	f.mutex.Unlock()
+}
+
+// staleResponse decodes the expired cached response, flagged as stale.
+func staleResponse(r *Request, cached CachedResponse, attempts int) *Response {
+	res := cached.Decode(r)
+	res.Stale = true
+	res.Attempts = attempts
+	return res
+}
+
+// staleErrorStatus returns true for the response statuses that allow a
+// "stale-if-error" response to be served.
+func staleErrorStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package sawyer
+
+import (
+	"github.com/bmizerany/assert"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRevalidateOncePerKey(t *testing.T) {
+	setup := Setup(t)
+	defer setup.Teardown()
+
+	release := make(chan bool)
+	var requests int32
+	setup.Mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	var handled int32
+	cacher := &revalidatingCacher{expiredCacher: &expiredCacher{&noOpCache{}}, Updated: make(chan bool, 1)}
+	client := setup.Client
+	client.ServeStale = true
+	client.Cacher = cacher
+	client.Middleware = []Middleware{func(req *Request, next HandlerFunc) *Response {
+		atomic.AddInt32(&handled, 1)
+		return next(req)
+	}}
+
+	for i := 0; i < 3; i++ {
+		req, err := client.NewRequest("user")
+		assert.Equal(t, nil, err)
+
+		res := req.Get()
+		assert.Equal(t, false, res.AnyError())
+		assert.Equal(t, true, res.Stale)
+	}
+
+	close(release)
+	<-cacher.Updated
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// three requests, and one revalidation through the middleware chain
+	assert.Equal(t, int32(4), atomic.LoadInt32(&handled))
+}
+
+type revalidatingCacher struct {
+	*expiredCacher
+	Updated chan bool
+}
+
+func (c *revalidatingCacher) Get(req *http.Request) (CachedResponse, error) {
+	return &revalidatingResponse{}, nil
+}
+
+func (c *revalidatingCacher) UpdateCache(req *http.Request, res *http.Response) error {
+	c.Updated <- true
+	return nil
+}
+
+func (c *revalidatingCacher) CacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+type revalidatingResponse struct {
+	expiredResponse
+}
+
+func (r *revalidatingResponse) StaleWhileRevalidate() bool {
+	return true
+}
+
+func (r *revalidatingResponse) StaleIfError() bool {
+	return false
+}